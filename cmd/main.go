@@ -1,44 +1,82 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/hidakatsuya/flexdot-go/internal/add"
 	"github.com/hidakatsuya/flexdot-go/internal/clearbackups"
 	"github.com/hidakatsuya/flexdot-go/internal/config"
+	"github.com/hidakatsuya/flexdot-go/internal/configcmd"
+	"github.com/hidakatsuya/flexdot-go/internal/diffcmd"
+	"github.com/hidakatsuya/flexdot-go/internal/expand"
 	initcmd "github.com/hidakatsuya/flexdot-go/internal/init"
 	"github.com/hidakatsuya/flexdot-go/internal/install"
+	"github.com/hidakatsuya/flexdot-go/internal/listcmd"
+	"github.com/hidakatsuya/flexdot-go/internal/restore"
+	"github.com/hidakatsuya/flexdot-go/internal/status"
+	"golang.org/x/term"
 )
 
 const version = "0.4.0"
 
+// dotfilesDirFlag is the global -C/--dotfiles-dir flag.
+var dotfilesDirFlag string
+
+// profileFlag is the --profile flag of the commands reading the config.
+var profileFlag string
+
+// addProfileFlag adds the --profile flag to fs.
+func addProfileFlag(fs *flag.FlagSet) {
+	fs.StringVar(&profileFlag, "profile", "", "Config profile to use (default: $FLEXDOT_PROFILE)")
+}
+
 func main() {
-	if len(os.Args) < 2 {
+	global := flag.NewFlagSet("flexdot", flag.ExitOnError)
+	global.StringVar(&dotfilesDirFlag, "dotfiles-dir", "", "Dotfiles directory")
+	global.StringVar(&dotfilesDirFlag, "C", "", "Dotfiles directory (shorthand)")
+	versionFlag := global.Bool("version", false, "Print the version")
+	versionShortFlag := global.Bool("v", false, "Print the version (shorthand)")
+	global.Usage = func() {
 		printUsage()
-		os.Exit(1)
 	}
+	global.Parse(os.Args[1:])
 
-	arg := os.Args[1]
-	if arg == "--version" || arg == "-v" {
+	if *versionFlag || *versionShortFlag {
 		fmt.Println(version)
 		return
 	}
+	if global.NArg() < 1 {
+		printUsage()
+		os.Exit(1)
+	}
 
+	arg, args := global.Arg(0), global.Args()[1:]
 	switch arg {
 	case "install":
-		runInstall(os.Args[2:])
+		runInstall(args)
+	case "status":
+		runStatus(args)
+	case "diff":
+		runDiff(args)
+	case "list":
+		runList(args)
+	case "which":
+		runWhich(args)
+	case "add":
+		runAdd(args)
 	case "init":
-		if err := initcmd.Run(); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to init: %v\n", err)
-			os.Exit(1)
-		}
+		runInit(args)
 	case "clear-backups":
-		if err := clearbackups.Run(); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to clear backups: %v\n", err)
-			os.Exit(1)
-		}
+		runClearBackups(args)
+	case "restore":
+		runRestore(args)
+	case "config":
+		runConfig(args)
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", arg)
 		printUsage()
@@ -48,27 +86,297 @@ func main() {
 
 func printUsage() {
 	usage := `
-Usage: flexdot <command> [options]
+Usage: flexdot [-C|--dotfiles-dir path] <command> [options]
 Commands:
-  install [-H|--home_dir path] <index.yml>
-  init
-  clear-backups`
+  install [-H|--home_dir path] [--profile name] [--strict] [--adopt|--interactive] [--verbose] <index.yml>
+  status [-H|--home_dir path] [--profile name] [--strict] [--verbose] <index.yml>
+  diff [-H|--home_dir path] [--profile name] [--strict] [--verbose] <index.yml>
+  list [-H|--home_dir path] [--profile name] [--strict] [--json] <index.yml>
+  which [-H|--home_dir path] [--profile name] [--index file] <home-path>
+  add [-H|--home_dir path] [--profile name] [--index file] --to <repo-dir> <path>
+  init [--home-dir path] [--index file] [--keep-max-count N] [--scaffold] [--yes]
+  clear-backups [--profile name] [--yes] [--older-than age] [--keep N] [--snapshot timestamp]
+  restore [-H|--home_dir path] [--profile name] [--snapshot timestamp] [--verbose]
+  config show [--profile name] [--origin]
+  config get [--profile name] <key>
+  config set [--local|--global] <key> <value>
+  config list [--profile name]`
 	fmt.Println(usage)
 }
 
+// resolveDotfilesDir returns the dotfiles directory: the -C/--dotfiles-dir
+// flag, then $FLEXDOT_DIR, then the closest directory from the current one up
+// holding a config.yml, and finally the current directory. Only the flag,
+// the variable and the current directory are used when discover is false.
+func resolveDotfilesDir(discover bool) string {
+	dir := dotfilesDirFlag
+	if dir == "" {
+		dir = os.Getenv("FLEXDOT_DIR")
+	}
+	if dir == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to get current directory: %v\n", err)
+			os.Exit(1)
+		}
+		dir = cwd
+		if discover {
+			if found := config.FindDotfilesDir(cwd); found != "" {
+				dir = found
+			}
+		}
+	}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid dotfiles directory: %v\n", err)
+		os.Exit(1)
+	}
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		fmt.Fprintf(os.Stderr, "Dotfiles directory %s is not a directory\n", dir)
+		os.Exit(1)
+	}
+	return dir
+}
+
+func runInit(args []string) {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	homeDirFlag := fs.String("home-dir", "", "Home directory to set in config.yml")
+	indexFlag := fs.String("index", "", "Index file to set in config.yml")
+	keepFlag := fs.Int("keep-max-count", -1, "Number of backups to keep")
+	scaffoldFlag := fs.Bool("scaffold", false, "Also create a starter index file and .gitignore")
+	yesFlag := fs.Bool("yes", false, "Do not prompt for values")
+	yesShortFlag := fs.Bool("y", false, "Do not prompt for values (shorthand)")
+	fs.Usage = func() {
+		printUsage()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "Too many arguments for init command\n")
+		printUsage()
+		os.Exit(1)
+	}
+
+	opts := initcmd.Options{
+		DotfilesDir:  resolveDotfilesDir(false),
+		HomeDir:      *homeDirFlag,
+		Index:        *indexFlag,
+		KeepMaxCount: *keepFlag,
+		Scaffold:     *scaffoldFlag,
+		Interactive:  isTerminal(os.Stdin) && !*yesFlag && !*yesShortFlag,
+		In:           os.Stdin,
+		Out:          os.Stdout,
+	}
+	if err := initcmd.Run(opts); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to init: %v\n", err)
+		os.Exit(1)
+	}
+}
+
 func runInstall(args []string) {
 	fs := flag.NewFlagSet("install", flag.ExitOnError)
+	idx := addIndexFlags(fs)
+	adoptFlag := fs.Bool("adopt", false, "Move conflicting home files into the dotfiles directory instead of backing them up")
+	interactiveFlag := fs.Bool("interactive", false, "Show a diff and ask what to do with each conflicting home file")
+	fs.Parse(args)
+	if *adoptFlag && *interactiveFlag {
+		fmt.Fprintf(os.Stderr, "--adopt and --interactive cannot be used together\n")
+		os.Exit(1)
+	}
+	target := idx.resolve("install", fs)
+
+	opts := target.installOptions()
+	switch {
+	case *adoptFlag:
+		opts.Resolve = install.Adopt
+	case *interactiveFlag:
+		opts.Resolve = install.PromptResolver(nil, opts.HomeDir, os.Stdin, os.Stdout)
+	}
+	if err := install.Run(opts); err != nil {
+		printInstallError(err)
+		fmt.Fprintf(os.Stderr, "Install failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runStatus(args []string) {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	idx := addIndexFlags(fs)
+	fs.Parse(args)
+	target := idx.resolve("status", fs)
+
+	if err := status.Run(target.installOptions()); err != nil {
+		fmt.Fprintf(os.Stderr, "Status: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	idx := addIndexFlags(fs)
+	fs.Parse(args)
+	target := idx.resolve("diff", fs)
+
+	if err := diffcmd.Run(target.installOptions()); err != nil {
+		fmt.Fprintf(os.Stderr, "Diff: %v\n", err)
+		// As diff(1): 1 when files differ, 2 when they could not be compared
+		var differ *diffcmd.DifferError
+		if errors.As(err, &differ) {
+			os.Exit(1)
+		}
+		os.Exit(2)
+	}
+}
+
+func runList(args []string) {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	idx := addIndexFlags(fs)
+	jsonFlag := fs.Bool("json", false, "Print the entries as JSON")
+	fs.Parse(args)
+	target := idx.resolve("list", fs)
+
+	if err := listcmd.List(os.Stdout, target.installOptions(), *jsonFlag); err != nil {
+		printInstallError(err)
+		fmt.Fprintf(os.Stderr, "List failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runWhich(args []string) {
+	fs := flag.NewFlagSet("which", flag.ExitOnError)
 	homeDirFlag := fs.String("home_dir", "", "Home directory")
 	homeDirShortFlag := fs.String("H", "", "Home directory (shorthand)")
+	indexFlag := fs.String("index", "", "Index file to look up")
+	verboseFlag := fs.Bool("verbose", false, "Show where settings come from")
+	addProfileFlag(fs)
 	fs.Usage = func() {
 		printUsage()
 	}
 	fs.Parse(args)
 
+	if fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "which takes a path\n")
+		printUsage()
+		os.Exit(1)
+	}
+
+	target := resolveIndexTarget(*indexFlag, *homeDirFlag, *homeDirShortFlag, *verboseFlag)
+	path, err := expand.Path(fs.Arg(0), target.homeDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid path: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := listcmd.Which(os.Stdout, target.installOptions(), path); err != nil {
+		printInstallError(err)
+		fmt.Fprintf(os.Stderr, "Which: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runAdd(args []string) {
+	fs := flag.NewFlagSet("add", flag.ExitOnError)
+	homeDirFlag := fs.String("home_dir", "", "Home directory")
+	homeDirShortFlag := fs.String("H", "", "Home directory (shorthand)")
+	indexFlag := fs.String("index", "", "Index file to add the entry to")
+	toFlag := fs.String("to", "", "Directory in the dotfiles directory to move the file into")
+	verboseFlag := fs.Bool("verbose", false, "Show where settings come from")
+	addProfileFlag(fs)
+	fs.Usage = func() {
+		printUsage()
+	}
+	paths := parseInterspersed(fs, args)
+
+	if len(paths) != 1 || *toFlag == "" {
+		fmt.Fprintf(os.Stderr, "add takes a path and --to <repo-dir>\n")
+		printUsage()
+		os.Exit(1)
+	}
+
+	target := resolveIndexTarget(*indexFlag, *homeDirFlag, *homeDirShortFlag, *verboseFlag)
+	path, err := expand.Path(paths[0], target.homeDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid path: %v\n", err)
+		os.Exit(1)
+	}
+
+	opts := add.Options{Options: target.installOptions(), Path: path, To: *toFlag}
+	if err := add.Run(opts); err != nil {
+		printInstallError(err)
+		fmt.Fprintf(os.Stderr, "Add failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// parseInterspersed parses args with fs like fs.Parse, but also reads the
+// flags after a positional argument, as in `add <path> --to dir`. It returns
+// the positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		if fs.NArg() == 0 {
+			return positional
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// indexFlags are the flags of the commands that read an index file.
+type indexFlags struct {
+	homeDir      *string
+	homeDirShort *string
+	strict       *bool
+	verbose      *bool
+}
+
+// indexTarget is what a command reading an index file operates on.
+type indexTarget struct {
+	indexFile   string
+	homeDir     string
+	dotfilesDir string
+	strict      bool
+	cfg         *config.Config
+}
+
+// installOptions returns the install options for the target.
+func (t indexTarget) installOptions() install.Options {
+	return install.Options{
+		IndexFile:          t.indexFile,
+		HomeDir:            t.homeDir,
+		DotfilesDir:        t.dotfilesDir,
+		BackupDir:          t.cfg.GetBackupDir(t.dotfilesDir),
+		KeepMaxBackupCount: t.cfg.GetKeepMaxCount(),
+		AllowOutsideHome:   t.cfg.GetAllowOutsideHome(),
+		DotPrefix:          t.cfg.GetDotPrefix(),
+		Vars:               t.cfg.GetVars(),
+		Strict:             t.strict,
+	}
+}
+
+func addIndexFlags(fs *flag.FlagSet) *indexFlags {
+	f := &indexFlags{
+		homeDir:      fs.String("home_dir", "", "Home directory"),
+		homeDirShort: fs.String("H", "", "Home directory (shorthand)"),
+		strict:       fs.Bool("strict", false, "Fail on wildcards that match no files"),
+		verbose:      fs.Bool("verbose", false, "Show where settings come from"),
+	}
+	addProfileFlag(fs)
+	fs.Usage = func() {
+		printUsage()
+	}
+	return f
+}
+
+// resolve loads config.yml and determines the index file and home directory
+// from the parsed flags, exiting on error.
+func (f *indexFlags) resolve(command string, fs *flag.FlagSet) indexTarget {
 	rest := fs.Args()
 	var indexFile string
 	if len(rest) > 1 {
-		fmt.Fprintf(os.Stderr, "Too many arguments for install command\n")
+		fmt.Fprintf(os.Stderr, "Too many arguments for %s command\n", command)
 		printUsage()
 		os.Exit(1)
 	}
@@ -76,49 +384,297 @@ func runInstall(args []string) {
 		indexFile = rest[0]
 	}
 
-	// dotfilesDir = current directory
-	dotfilesDir, err := os.Getwd()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to get current directory: %v\n", err)
+	target := resolveIndexTarget(indexFile, *f.homeDir, *f.homeDirShort, *f.verbose)
+	target.strict = *f.strict || target.cfg.GetStrict()
+	return target
+}
+
+// resolveIndexTarget loads config.yml and determines the index file, unless
+// given, and home directory, exiting on error.
+func resolveIndexTarget(indexFile, homeDirFlag, homeDirShortFlag string, verbose bool) indexTarget {
+	dotfilesDir := resolveDotfilesDir(true)
+
+	cfg := mustLoadConfig(dotfilesDir)
+
+	homeDir := mustResolveHomeDir(homeDirFlag, homeDirShortFlag, cfg, verbose)
+
+	// An index file given on the command line is relative to the current
+	// directory, like any path argument; index_yml to the dotfiles dir
+	if indexFile != "" {
+		abs, err := filepath.Abs(indexFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid index file: %v\n", err)
+			os.Exit(1)
+		}
+		indexFile = abs
+	} else if cfg != nil && cfg.IndexYml != "" {
+		indexFile = cfg.IndexYml
+		if !filepath.IsAbs(indexFile) {
+			indexFile = filepath.Join(dotfilesDir, indexFile)
+		}
+	}
+	if indexFile == "" {
+		fmt.Fprintf(os.Stderr, "<index.yml> must be specified as argument or config.yml\n")
 		os.Exit(1)
 	}
 
-	cfg, err := config.LoadConfig(dotfilesDir)
+	return indexTarget{
+		indexFile:   indexFile,
+		homeDir:     homeDir,
+		dotfilesDir: dotfilesDir,
+		cfg:         cfg,
+	}
+}
+
+// resolveHomeDir returns the home directory from the --home_dir/-H flags,
+// config.yml or the current user, in that order, along with where it came
+// from.
+func resolveHomeDir(homeDirFlag, homeDirShortFlag string, cfg *config.Config) (string, string, error) {
+	if homeDirFlag != "" {
+		return homeDirFlag, "--home_dir", nil
+	} else if homeDirShortFlag != "" {
+		return homeDirShortFlag, "-H", nil
+	} else if cfg != nil && cfg.HomeDir != "" {
+		return cfg.HomeDir, cfg.Origin("home_dir"), nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", "", err
+	}
+	return homeDir, "the current user's home directory", nil
+}
+
+// mustResolveHomeDir is resolveHomeDir, exiting on error and printing the
+// source of the home directory when verbose is set.
+func mustResolveHomeDir(homeDirFlag, homeDirShortFlag string, cfg *config.Config, verbose bool) string {
+	homeDir, source, err := resolveHomeDir(homeDirFlag, homeDirShortFlag, cfg)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to load config.yml: %v\n", err)
+		fmt.Fprintf(os.Stderr, "home_dir must be specified by --home_dir/-H or config.yml: %v\n", err)
 		os.Exit(1)
 	}
+	if verbose {
+		fmt.Fprintf(os.Stderr, "home_dir: %s (from %s)\n", homeDir, source)
+	}
+	return homeDir
+}
 
-	homeDir := ""
-	if *homeDirFlag != "" {
-		homeDir = *homeDirFlag
-	} else if *homeDirShortFlag != "" {
-		homeDir = *homeDirShortFlag
-	} else if cfg != nil && cfg.HomeDir != "" {
-		homeDir = cfg.HomeDir
+func runRestore(args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	homeDirFlag := fs.String("home_dir", "", "Home directory")
+	homeDirShortFlag := fs.String("H", "", "Home directory (shorthand)")
+	snapshotFlag := fs.String("snapshot", "", "Backup to restore (default: the newest)")
+	verboseFlag := fs.Bool("verbose", false, "Show where settings come from")
+	addProfileFlag(fs)
+	fs.Usage = func() {
+		printUsage()
 	}
-	if homeDir == "" {
-		fmt.Fprintf(os.Stderr, "home_dir must be specified by --home_dir/-h or config.yml\n")
+	fs.Parse(args)
+
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "Too many arguments for restore command\n")
+		printUsage()
 		os.Exit(1)
 	}
 
-	if indexFile == "" && cfg != nil && cfg.IndexYml != "" {
-		indexFile = cfg.IndexYml
+	dotfilesDir := resolveDotfilesDir(true)
+
+	cfg := mustLoadConfig(dotfilesDir)
+
+	homeDir := mustResolveHomeDir(*homeDirFlag, *homeDirShortFlag, cfg, *verboseFlag)
+
+	opts := restore.Options{
+		BackupDir: cfg.GetBackupDir(dotfilesDir),
+		HomeDir:   homeDir,
+		Snapshot:  *snapshotFlag,
+		Out:       os.Stdout,
 	}
-	if indexFile == "" {
-		fmt.Fprintf(os.Stderr, "<index.yml> must be specified as argument or config.yml\n")
+	if err := restore.Run(opts); err != nil {
+		printRestoreError(err)
+		fmt.Fprintf(os.Stderr, "Restore failed: %v\n", err)
 		os.Exit(1)
 	}
+}
 
-	keepMaxBackupCount := cfg.GetKeepMaxCount()
+func runClearBackups(args []string) {
+	fs := flag.NewFlagSet("clear-backups", flag.ExitOnError)
+	yesFlag := fs.Bool("yes", false, "Delete without confirmation")
+	yesShortFlag := fs.Bool("y", false, "Delete without confirmation (shorthand)")
+	olderThanFlag := fs.String("older-than", "", "Only delete backups older than the age (e.g. 30d)")
+	keepFlag := fs.Int("keep", -1, "Keep the N newest backups")
+	snapshotFlag := fs.String("snapshot", "", "Only delete the backup with the timestamp")
+	addProfileFlag(fs)
+	fs.Usage = func() {
+		printUsage()
+	}
+	fs.Parse(args)
 
-	// indexFile may be relative to dotfilesDir
-	if !filepath.IsAbs(indexFile) {
-		indexFile = filepath.Join(dotfilesDir, indexFile)
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "Too many arguments for clear-backups command\n")
+		printUsage()
+		os.Exit(1)
 	}
 
-	if err := install.Run(indexFile, homeDir, dotfilesDir, keepMaxBackupCount); err != nil {
-		fmt.Fprintf(os.Stderr, "Install failed: %v\n", err)
+	var olderThan time.Duration
+	if *olderThanFlag != "" {
+		d, err := clearbackups.ParseAge(*olderThanFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid --older-than: %v\n", err)
+			os.Exit(1)
+		}
+		olderThan = d
+	}
+
+	dotfilesDir := resolveDotfilesDir(true)
+
+	cfg := mustLoadConfig(dotfilesDir)
+
+	opts := clearbackups.Options{
+		BackupDir:   cfg.GetBackupDir(dotfilesDir),
+		OlderThan:   olderThan,
+		Keep:        *keepFlag,
+		Snapshot:    *snapshotFlag,
+		Yes:         *yesFlag || *yesShortFlag,
+		Interactive: isTerminal(os.Stdin),
+		In:          os.Stdin,
+		Out:         os.Stdout,
+	}
+	if err := clearbackups.Run(opts); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to clear backups: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runConfig(args []string) {
+	if len(args) < 1 {
+		printUsage()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "show":
+		fs := flag.NewFlagSet("config show", flag.ExitOnError)
+		originFlag := fs.Bool("origin", false, "Show where each value comes from")
+		addProfileFlag(fs)
+		fs.Usage = func() {
+			printUsage()
+		}
+		fs.Parse(args[1:])
+		if fs.NArg() > 0 {
+			fmt.Fprintf(os.Stderr, "Too many arguments for config show command\n")
+			printUsage()
+			os.Exit(1)
+		}
+
+		cfg := mustLoadConfig(resolveDotfilesDir(true))
+		if err := configcmd.Show(os.Stdout, cfg, *originFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to show config: %v\n", err)
+			os.Exit(1)
+		}
+	case "get":
+		fs := flag.NewFlagSet("config get", flag.ExitOnError)
+		addProfileFlag(fs)
+		fs.Usage = func() {
+			printUsage()
+		}
+		fs.Parse(args[1:])
+		if fs.NArg() != 1 {
+			fmt.Fprintf(os.Stderr, "config get takes a key\n")
+			printUsage()
+			os.Exit(1)
+		}
+
+		cfg := mustLoadConfig(resolveDotfilesDir(true))
+		if err := configcmd.Get(os.Stdout, cfg, fs.Arg(0)); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to get config: %v\n", err)
+			os.Exit(1)
+		}
+	case "list":
+		fs := flag.NewFlagSet("config list", flag.ExitOnError)
+		addProfileFlag(fs)
+		fs.Usage = func() {
+			printUsage()
+		}
+		fs.Parse(args[1:])
+		if fs.NArg() > 0 {
+			fmt.Fprintf(os.Stderr, "Too many arguments for config list command\n")
+			printUsage()
+			os.Exit(1)
+		}
+
+		configcmd.List(os.Stdout, mustLoadConfig(resolveDotfilesDir(true)))
+	case "set":
+		fs := flag.NewFlagSet("config set", flag.ExitOnError)
+		localFlag := fs.Bool("local", false, "Write config.local.yml")
+		globalFlag := fs.Bool("global", false, "Write the per-user config file")
+		fs.Usage = func() {
+			printUsage()
+		}
+		fs.Parse(args[1:])
+		if fs.NArg() != 2 {
+			fmt.Fprintf(os.Stderr, "config set takes a key and a value\n")
+			printUsage()
+			os.Exit(1)
+		}
+
+		dotfilesDir := resolveDotfilesDir(true)
+		path := filepath.Join(dotfilesDir, "config.yml")
+		switch {
+		case *localFlag && *globalFlag:
+			fmt.Fprintf(os.Stderr, "--local and --global cannot be used together\n")
+			os.Exit(1)
+		case *localFlag:
+			path = filepath.Join(dotfilesDir, "config.local.yml")
+		case *globalFlag:
+			path = config.GlobalPath()
+			if path == "" {
+				fmt.Fprintf(os.Stderr, "Failed to locate the per-user config file\n")
+				os.Exit(1)
+			}
+		}
+		if err := configcmd.Set(dotfilesDir, path, fs.Arg(0), fs.Arg(1)); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to set config: %v\n", err)
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown config command: %s\n", args[0])
+		printUsage()
 		os.Exit(1)
 	}
 }
+
+// mustLoadConfig loads the layered config of dotfilesDir with the profile
+// of the --profile flag, exiting on error.
+func mustLoadConfig(dotfilesDir string) *config.Config {
+	cfg, err := config.LoadConfig(dotfilesDir, profileFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+	return cfg
+}
+
+func printInstallError(err error) {
+	var installErr *install.InstallError
+	if !errors.As(err, &installErr) {
+		return
+	}
+	for _, e := range installErr.Errors {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", e)
+	}
+}
+
+func printRestoreError(err error) {
+	var restoreErr *restore.RestoreError
+	if !errors.As(err, &restoreErr) {
+		return
+	}
+	for _, e := range restoreErr.Errors {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", e)
+	}
+}
+
+// isTerminal reports whether f is attached to a terminal. Other character
+// devices such as /dev/null are not.
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
@@ -0,0 +1,186 @@
+package add
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/hidakatsuya/flexdot-go/internal/install"
+	"gopkg.in/yaml.v3"
+)
+
+// insertEntry adds the index entry for the dotfile at keys, its path in the
+// dotfiles dir split into segments, linking into the home directory value.
+// Existing directories of the index are reused and the new keys appended to
+// them. The rest of the document, including comments and blank lines, is
+// kept as it is.
+func insertEntry(data []byte, keys []string, value string) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode index yaml: %w", err)
+	}
+	if doc.Kind == 0 {
+		// Empty or missing file
+		return appendLines(data, len(data), newLines(keys, value, 0, 2)), nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("index is not a mapping")
+	}
+
+	// Find the deepest existing directory of the index along keys
+	node, depth := root, 0
+	for ; depth < len(keys); depth++ {
+		child := lookup(node, keys[depth])
+		if child == nil {
+			break
+		}
+		path := strings.Join(keys[:depth+1], "/")
+		if depth == len(keys)-1 {
+			return nil, fmt.Errorf("%s is already in the index", path)
+		}
+		if child.Kind != yaml.MappingNode || isLeafOptions(child) {
+			return nil, fmt.Errorf("%s is an entry of the index, not a directory", path)
+		}
+		node = child
+	}
+
+	step := indentStep(root)
+	if node.Style&yaml.FlowStyle == 0 && !hasMultilineScalar(node) {
+		indent := node.Content[0].Column - 1
+		return appendLines(data, lineOffset(data, endLine(node)), newLines(keys[depth:], value, indent, step)), nil
+	}
+
+	// Flow mappings and multi-line values cannot be extended in place;
+	// re-encode the document, which keeps the comments but not blank lines
+	node.Content = append(node.Content, newNodes(keys[depth:], value)...)
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(step)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// lookup returns the value of key in the mapping node, or nil.
+func lookup(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// isLeafOptions reports whether the mapping node is an entry with options
+// rather than a directory of the index.
+func isLeafOptions(node *yaml.Node) bool {
+	for i := 0; i < len(node.Content); i += 2 {
+		if install.IsLeafOptionKey(node.Content[i].Value) {
+			return true
+		}
+	}
+	return false
+}
+
+// indentStep returns the indentation of the nested mappings of the index,
+// defaulting to 2.
+func indentStep(node *yaml.Node) int {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		child := node.Content[i+1]
+		if child.Kind == yaml.MappingNode && child.Style&yaml.FlowStyle == 0 && len(child.Content) > 0 {
+			if step := child.Content[0].Column - node.Content[i].Column; step > 0 {
+				return step
+			}
+		}
+	}
+	return 2
+}
+
+// endLine returns the last line of node and its descendants.
+func endLine(node *yaml.Node) int {
+	line := node.Line
+	for _, child := range node.Content {
+		line = max(line, endLine(child))
+	}
+	return line
+}
+
+func hasMultilineScalar(node *yaml.Node) bool {
+	if node.Kind == yaml.ScalarNode {
+		return node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 || strings.Contains(node.Value, "\n")
+	}
+	for _, child := range node.Content {
+		if hasMultilineScalar(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// lineOffset returns the offset in data just after the given 1-based line.
+func lineOffset(data []byte, line int) int {
+	offset := 0
+	for i := 0; i < line; i++ {
+		n := bytes.IndexByte(data[offset:], '\n')
+		if n < 0 {
+			return len(data)
+		}
+		offset += n + 1
+	}
+	return offset
+}
+
+// appendLines inserts lines into data at offset, the start of a line or the
+// end of data.
+func appendLines(data []byte, offset int, lines string) []byte {
+	var out []byte
+	out = append(out, data[:offset]...)
+	if len(out) > 0 && !bytes.HasSuffix(out, []byte("\n")) {
+		out = append(out, '\n')
+	}
+	out = append(out, lines...)
+	return append(out, data[offset:]...)
+}
+
+// newLines returns the YAML lines of the nested keys ending with value, the
+// first key indented by indent spaces and each next one by step more.
+func newLines(keys []string, value string, indent, step int) string {
+	var b strings.Builder
+	for i, key := range keys {
+		b.WriteString(strings.Repeat(" ", indent+i*step))
+		b.WriteString(formatString(key) + ":")
+		if i == len(keys)-1 {
+			b.WriteString(" " + formatString(value))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// newNodes returns the key and value nodes of the nested keys ending with
+// value.
+func newNodes(keys []string, value string) []*yaml.Node {
+	child := stringNode(value)
+	for i := len(keys) - 1; i > 0; i-- {
+		child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{stringNode(keys[i]), child}}
+	}
+	return []*yaml.Node{stringNode(keys[0]), child}
+}
+
+func stringNode(s string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s}
+}
+
+// formatString returns s as a YAML scalar, quoted when needed.
+func formatString(s string) string {
+	out, err := yaml.Marshal(stringNode(s))
+	if err != nil {
+		return s
+	}
+	return strings.TrimSuffix(string(out), "\n")
+}
@@ -0,0 +1,81 @@
+package add
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInsertEntry(t *testing.T) {
+	tests := []struct {
+		name  string
+		index string
+		keys  []string
+		value string
+		want  string
+	}{
+		{
+			name:  "empty index",
+			index: "",
+			keys:  []string{"common", "foo", "bar.toml"},
+			value: ".config/foo",
+			want:  "common:\n  foo:\n    bar.toml: .config/foo\n",
+		},
+		{
+			name:  "existing directory keeps comments and blank lines",
+			index: "# my dotfiles\ncommon:\n    # shell\n    bash:\n        .bashrc: .\n\n    vim:\n        .vimrc: .\n\nmacOS:\n    .zshrc: .\n",
+			keys:  []string{"common", "bash", ".bash_profile"},
+			value: ".",
+			want:  "# my dotfiles\ncommon:\n    # shell\n    bash:\n        .bashrc: .\n        .bash_profile: .\n\n    vim:\n        .vimrc: .\n\nmacOS:\n    .zshrc: .\n",
+		},
+		{
+			name:  "new directory",
+			index: "common:\n  vim:\n    .vimrc: .\nmacOS:\n  .zshrc: .",
+			keys:  []string{"common", "nvim", "init.lua"},
+			value: ".config/nvim",
+			want:  "common:\n  vim:\n    .vimrc: .\n  nvim:\n    init.lua: .config/nvim\nmacOS:\n  .zshrc: .",
+		},
+		{
+			name:  "quoted keys",
+			index: "common:\n  bin/*: bin\n",
+			keys:  []string{"common", "*weird"},
+			value: ".",
+			want:  "common:\n  bin/*: bin\n  '*weird': .\n",
+		},
+		{
+			name:  "flow mapping",
+			index: "common: {vim: {.vimrc: .}}\n",
+			keys:  []string{"common", "git", ".gitconfig"},
+			value: ".",
+			want:  "common: {vim: {.vimrc: .}, git: {.gitconfig: .}}\n",
+		},
+	}
+	for _, tt := range tests {
+		got, err := insertEntry([]byte(tt.index), tt.keys, tt.value)
+		if err != nil {
+			t.Errorf("%s: insertEntry failed: %v", tt.name, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: insertEntry =\n%s\nwant:\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInsertEntryConflicts(t *testing.T) {
+	tests := []struct {
+		index string
+		keys  []string
+		want  string
+	}{
+		{"common:\n  .vimrc: .\n", []string{"common", ".vimrc"}, "already in the index"},
+		{"common: .\n", []string{"common", ".vimrc"}, "not a directory"},
+		{"common:\n  to: .config\n", []string{"common", ".vimrc"}, "not a directory"},
+		{"- a\n", []string{".vimrc"}, "not a mapping"},
+	}
+	for _, tt := range tests {
+		_, err := insertEntry([]byte(tt.index), tt.keys, ".")
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("insertEntry(%q, %v): expected an error containing %q, got %v", tt.index, tt.keys, tt.want, err)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package add
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hidakatsuya/flexdot-go/internal/fsys"
+)
+
+// errReadOnly is returned by the write operations of pendingFS.
+var errReadOnly = errors.New("read-only view of a pending add")
+
+// pendingFS is a read-only view of base as it will be after the add: the
+// index file has its edited content and the home file is at the path of its
+// dotfile, along with any missing parent directories. It lets the edited
+// index be checked before anything is changed.
+type pendingFS struct {
+	base      fsys.FS
+	indexFile string
+	index     []byte
+	dotfile   string // absolute path of the dotfile to be
+	homeFile  string // absolute path of the file moved there
+}
+
+// source returns the path in base holding name, and whether name is the
+// dotfile or inside it.
+func (p *pendingFS) source(name string) (string, bool) {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return name, false
+	}
+	if abs == p.dotfile {
+		return p.homeFile, true
+	}
+	if rest, ok := strings.CutPrefix(abs, p.dotfile+string(filepath.Separator)); ok {
+		return filepath.Join(p.homeFile, rest), true
+	}
+	return name, false
+}
+
+// isParent reports whether name is a directory the dotfile will be in.
+func (p *pendingFS) isParent(name string) bool {
+	abs, err := filepath.Abs(name)
+	return err == nil && strings.HasPrefix(p.dotfile, strings.TrimSuffix(abs, string(filepath.Separator))+string(filepath.Separator))
+}
+
+func (p *pendingFS) isIndex(name string) bool {
+	abs, err := filepath.Abs(name)
+	index, ierr := filepath.Abs(p.indexFile)
+	return err == nil && ierr == nil && abs == index
+}
+
+func (p *pendingFS) Stat(name string) (fs.FileInfo, error) {
+	if src, ok := p.source(name); ok {
+		return p.base.Stat(src)
+	}
+	fi, err := p.base.Stat(name)
+	if errors.Is(err, fs.ErrNotExist) && p.isParent(name) {
+		return dirInfo(filepath.Base(name)), nil
+	}
+	return fi, err
+}
+
+func (p *pendingFS) Lstat(name string) (fs.FileInfo, error) {
+	if src, ok := p.source(name); ok {
+		return p.base.Lstat(src)
+	}
+	fi, err := p.base.Lstat(name)
+	if errors.Is(err, fs.ErrNotExist) && p.isParent(name) {
+		return dirInfo(filepath.Base(name)), nil
+	}
+	return fi, err
+}
+
+func (p *pendingFS) Readlink(name string) (string, error) {
+	src, _ := p.source(name)
+	return p.base.Readlink(src)
+}
+
+func (p *pendingFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if src, ok := p.source(name); ok {
+		return p.base.ReadDir(src)
+	}
+	entries, err := p.base.ReadDir(name)
+	if !p.isParent(name) {
+		return entries, err
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	// Add the next directory on the way to the dotfile, or the dotfile
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return nil, err
+	}
+	rest := strings.TrimPrefix(p.dotfile, strings.TrimSuffix(abs, string(filepath.Separator))+string(filepath.Separator))
+	child, _, _ := strings.Cut(rest, string(filepath.Separator))
+	for _, entry := range entries {
+		if entry.Name() == child {
+			return entries, nil
+		}
+	}
+	info, err := p.Lstat(filepath.Join(abs, child))
+	if err != nil {
+		return nil, err
+	}
+	entries = append(entries, fs.FileInfoToDirEntry(info))
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (p *pendingFS) ReadFile(name string) ([]byte, error) {
+	if p.isIndex(name) {
+		return p.index, nil
+	}
+	src, _ := p.source(name)
+	return p.base.ReadFile(src)
+}
+
+func (p *pendingFS) Symlink(oldname, newname string) error        { return errReadOnly }
+func (p *pendingFS) Rename(oldpath, newpath string) error         { return errReadOnly }
+func (p *pendingFS) MkdirAll(path string, perm fs.FileMode) error { return errReadOnly }
+func (p *pendingFS) Remove(name string) error                     { return errReadOnly }
+func (p *pendingFS) RemoveAll(path string) error                  { return errReadOnly }
+
+// dirInfo is the FileInfo of a directory that does not exist yet.
+type dirInfo string
+
+func (d dirInfo) Name() string       { return string(d) }
+func (d dirInfo) Size() int64        { return 0 }
+func (d dirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0755 }
+func (d dirInfo) ModTime() time.Time { return time.Time{} }
+func (d dirInfo) IsDir() bool        { return true }
+func (d dirInfo) Sys() any           { return nil }
@@ -0,0 +1,44 @@
+package add
+
+import (
+	"testing"
+
+	"github.com/hidakatsuya/flexdot-go/internal/fsys"
+)
+
+func TestPendingFS(t *testing.T) {
+	mem := fsys.NewMemFS()
+	mem.WriteFile("/dotfiles/index.yml", []byte("old"), 0644)
+	mem.WriteFile("/dotfiles/apps/foo/config.toml", nil, 0644)
+	mem.WriteFile("/home/.config/baz/config.toml", []byte("b = 2"), 0644)
+	p := &pendingFS{base: mem, indexFile: "/dotfiles/index.yml", index: []byte("new"), dotfile: "/dotfiles/apps/baz/config.toml", homeFile: "/home/.config/baz/config.toml"}
+
+	if data, err := p.ReadFile("/dotfiles/index.yml"); err != nil || string(data) != "new" {
+		t.Errorf("index = %q (%v), want the edited one", data, err)
+	}
+	if data, err := p.ReadFile("/dotfiles/apps/baz/config.toml"); err != nil || string(data) != "b = 2" {
+		t.Errorf("dotfile = %q (%v), want the home file", data, err)
+	}
+	if fi, err := p.Stat("/dotfiles/apps/baz"); err != nil || !fi.IsDir() {
+		t.Errorf("the parent of the dotfile should be a directory: %v", err)
+	}
+
+	entries, err := p.ReadDir("/dotfiles/apps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if len(names) != 2 || names[0] != "baz" || names[1] != "foo" {
+		t.Errorf("ReadDir = %v, want [baz foo]", names)
+	}
+	if entries, err := p.ReadDir("/dotfiles/apps/baz"); err != nil || len(entries) != 1 || entries[0].IsDir() {
+		t.Errorf("ReadDir of the new parent = %v (%v), want the dotfile", entries, err)
+	}
+
+	if err := p.Rename("/a", "/b"); err == nil {
+		t.Error("expected pendingFS to be read-only")
+	}
+}
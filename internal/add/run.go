@@ -0,0 +1,156 @@
+// Package add brings an existing file of the home directory under the
+// management of flexdot.
+package add
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hidakatsuya/flexdot-go/internal/fsys"
+	"github.com/hidakatsuya/flexdot-go/internal/glob"
+	"github.com/hidakatsuya/flexdot-go/internal/install"
+)
+
+type Options struct {
+	install.Options
+
+	Path string // file or directory in the home directory to add
+	To   string // directory in the dotfiles dir to move it into
+}
+
+// Run moves the file at opts.Path into opts.To in the dotfiles dir, adds its
+// entry to the index file and links it back, printing the result with
+// OutputLog unless opts.Reporter is set.
+func Run(opts Options) error {
+	if opts.Reporter == nil {
+		opts.Reporter = install.LogReporter(opts.HomeDir)
+	}
+
+	homeFile, err := filepath.Abs(opts.Path)
+	if err != nil {
+		return err
+	}
+	fi, err := os.Lstat(homeFile)
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("%s is a symlink", opts.Path)
+	}
+	if !fi.Mode().IsRegular() && !fi.IsDir() {
+		return fmt.Errorf("%s is not a regular file or directory", opts.Path)
+	}
+
+	// The destination of the entry, relative to the home directory
+	homeDir, err := filepath.Abs(opts.HomeDir)
+	if err != nil {
+		return err
+	}
+	dest, err := filepath.Rel(homeDir, filepath.Dir(homeFile))
+	if err != nil || dest == ".." || strings.HasPrefix(dest, ".."+string(filepath.Separator)) {
+		if !opts.AllowOutsideHome {
+			return fmt.Errorf("%s is outside the home directory", opts.Path)
+		}
+		dest = filepath.Dir(homeFile)
+	}
+
+	to := filepath.Clean(opts.To)
+	if filepath.IsAbs(to) || to == ".." || strings.HasPrefix(to, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("--to must be a directory inside the dotfiles directory: %s", opts.To)
+	}
+	dotfilePath := filepath.Join(to, filepath.Base(homeFile))
+	dotfile := filepath.Join(opts.DotfilesDir, dotfilePath)
+	if _, err := os.Lstat(dotfile); err == nil {
+		return fmt.Errorf("%s already exists in the dotfiles directory", dotfilePath)
+	}
+
+	// Escape the wildcard syntax so that e.g. foo[1].toml names only itself
+	keys := strings.Split(filepath.ToSlash(dotfilePath), "/")
+	for i, key := range keys {
+		keys[i] = glob.Escape(key)
+	}
+
+	// Check the entry as install would before touching anything
+	entry := install.Entry{
+		DotfilePath:  filepath.ToSlash(dotfilePath),
+		HomeFilePath: dest,
+		HomeFileName: filepath.Base(homeFile),
+		IndexFile:    opts.IndexFile,
+		IndexKey:     strings.Join(keys, "/"),
+	}
+	installer := install.New(opts.Options)
+	if _, _, entryErr := installer.Paths(entry); entryErr != nil {
+		return entryErr
+	}
+
+	index, err := os.ReadFile(opts.IndexFile)
+	indexExists := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to open index file: %w", err)
+	}
+	edited, err := insertEntry(index, keys, filepath.ToSlash(dest))
+	if err != nil {
+		return fmt.Errorf("failed to add to %s: %w", opts.IndexFile, err)
+	}
+
+	// Read the edited index as install will once the file is moved, so that
+	// e.g. a wildcard also linking it is caught
+	dotfileAbs, err := filepath.Abs(dotfile)
+	if err != nil {
+		return err
+	}
+	pending := opts.Options
+	pending.FS = &pendingFS{base: fsys.Or(opts.FS), indexFile: opts.IndexFile, index: edited, dotfile: dotfileAbs, homeFile: homeFile}
+	pending.Warn = nil
+	if _, err := install.New(pending).Entries(); err != nil {
+		return fmt.Errorf("cannot add %s: %w", opts.Path, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dotfile), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(homeFile, dotfile); err != nil {
+		return fmt.Errorf("failed to move %s into the dotfiles directory: %w", opts.Path, err)
+	}
+	if err := os.WriteFile(opts.IndexFile, edited, 0644); err != nil {
+		if rerr := os.Rename(dotfile, homeFile); rerr != nil {
+			return fmt.Errorf("failed to write %s: %w (and to move %s back: %v)", opts.IndexFile, err, dotfilePath, rerr)
+		}
+		return fmt.Errorf("failed to write %s: %w", opts.IndexFile, err)
+	}
+
+	// Link it back, putting everything as it was if that fails
+	if steps, planErr := installer.Plan([]install.Entry{entry}); planErr != nil {
+		err = planErr
+	} else if applyErr := installer.Apply(steps); applyErr != nil {
+		err = applyErr
+	}
+	if err != nil {
+		if rerr := undo(homeFile, dotfile, opts.IndexFile, index, indexExists); rerr != nil {
+			return fmt.Errorf("%w (and to undo the add: %v)", err, rerr)
+		}
+		return err
+	}
+	return nil
+}
+
+// undo moves the dotfile back to homeFile, removing the link made in its
+// place if any, and restores the index file to its content before the add.
+func undo(homeFile, dotfile, indexFile string, index []byte, indexExists bool) error {
+	if fi, err := os.Lstat(homeFile); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(homeFile); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(dotfile, homeFile); err != nil {
+		return err
+	}
+	if !indexExists {
+		return os.Remove(indexFile)
+	}
+	return os.WriteFile(indexFile, index, 0644)
+}
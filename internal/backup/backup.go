@@ -1,64 +1,199 @@
 package backup
 
 import (
-	"os"
+	"errors"
+	"fmt"
+	"io/fs"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
+
+	"github.com/hidakatsuya/flexdot-go/internal/fsys"
 )
 
-const baseDir = "backup"
+const (
+	dirName = "backup"
+
+	// SnapshotLayout is the time layout of snapshot directory names.
+	SnapshotLayout = "20060102150405"
+
+	// absDir is the directory of a snapshot holding the files backed up
+	// from outside the base directory, under their absolute paths.
+	absDir = "_abs"
+)
+
+// DefaultDir returns the backup directory used when none is configured.
+func DefaultDir(dotfilesDir string) string {
+	return filepath.Join(dotfilesDir, dirName)
+}
+
+// Snapshot is a timestamped backup directory.
+type Snapshot struct {
+	Name string
+	Path string
+	Time time.Time
+}
+
+// Store manages the timestamped backup directories under dir.
+type Store struct {
+	fs  fsys.FS
+	dir string
+
+	// snapshot is the name of the snapshot of the current run, fixed by
+	// its first BackupFile so that a run never spans several snapshots.
+	snapshot string
+}
+
+func NewStore(filesystem fsys.FS, dir string) *Store {
+	return &Store{fs: fsys.Or(filesystem), dir: dir}
+}
+
+// BeginRun starts a new run: the next BackupFile names a new snapshot.
+func (s *Store) BeginRun() {
+	s.snapshot = ""
+}
+
+// Snapshot returns the name of the snapshot of the current run, or "" when
+// nothing has been backed up since BeginRun.
+func (s *Store) Snapshot() string {
+	return s.snapshot
+}
 
-func BackupFile(file string) (string, error) {
-	backupDir := filepath.Join(baseDir, time.Now().Format("20060102150405"))
-	if err := os.MkdirAll(backupDir, 0755); err != nil {
+// BackupFile moves file into the snapshot of the current run, keeping its
+// path relative to baseDir, or its absolute path under _abs when it is
+// outside baseDir, so that it can be restored. Symlinks are moved as they
+// are. A file already backed up at the same path is never overwritten.
+func (s *Store) BackupFile(baseDir, file string) (string, error) {
+	if s.snapshot == "" {
+		s.snapshot = time.Now().Format(SnapshotLayout)
+	}
+	backupDir := filepath.Join(s.dir, s.snapshot)
+	rel, err := backupPath(baseDir, file)
+	if err != nil {
+		return "", err
+	}
+	dest := filepath.Join(backupDir, rel)
+	if _, err := s.fs.Lstat(dest); err == nil {
+		return "", fmt.Errorf("%s is already backed up in %s", file, backupDir)
+	}
+	if err := s.fs.MkdirAll(filepath.Dir(dest), 0755); err != nil {
 		return "", err
 	}
-	base := filepath.Base(file)
-	dest := filepath.Join(backupDir, base)
-	if err := os.Rename(file, dest); err != nil {
+	if err := s.fs.Rename(file, dest); err != nil {
 		return "", err
 	}
 	return backupDir, nil
 }
 
-func RemoveBackupDirIfEmpty(backupDir string) {
-	entries, err := os.ReadDir(backupDir)
-	if err == nil && len(entries) == 0 {
-		os.Remove(backupDir)
+// backupPath returns the path of file in a snapshot. A file of baseDir whose
+// path starts with _abs is stored under its absolute path as well, so that
+// OriginalPath is never ambiguous.
+func backupPath(baseDir, file string) (string, error) {
+	rel, err := filepath.Rel(baseDir, file)
+	if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !isAbsPath(rel) {
+		return rel, nil
 	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(absDir, abs), nil
 }
 
-func RemoveOutdatedBackups(keepMaxCount int) {
-	if keepMaxCount <= 0 {
-		return
+// OriginalPath returns where the file at rel in a snapshot was backed up
+// from, the inverse of the path BackupFile gives it.
+func OriginalPath(baseDir, rel string) string {
+	if isAbsPath(rel) {
+		return filepath.Join(string(filepath.Separator), strings.TrimPrefix(rel, absDir))
 	}
-	entries, err := os.ReadDir(baseDir)
+	return filepath.Join(baseDir, rel)
+}
+
+// isAbsPath reports whether rel is under the _abs directory of a snapshot.
+func isAbsPath(rel string) bool {
+	return strings.HasPrefix(rel, absDir+string(filepath.Separator))
+}
+
+// Files returns the paths of the backed up files in snapshot, relative to
+// the snapshot directory.
+func (s *Store) Files(snapshot Snapshot) ([]string, error) {
+	var files []string
+	var walk func(rel string) error
+	walk = func(rel string) error {
+		entries, err := s.fs.ReadDir(filepath.Join(snapshot.Path, rel))
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			child := filepath.Join(rel, entry.Name())
+			if entry.IsDir() {
+				if err := walk(child); err != nil {
+					return err
+				}
+				continue
+			}
+			files = append(files, child)
+		}
+		return nil
+	}
+	if err := walk(""); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func (s *Store) RemoveBackupDirIfEmpty(backupDir string) {
+	entries, err := s.fs.ReadDir(backupDir)
+	if err == nil && len(entries) == 0 {
+		s.fs.Remove(backupDir)
+	}
+}
+
+// Snapshots returns the snapshot directories, oldest first. Entries whose
+// names do not follow SnapshotLayout are ignored.
+func (s *Store) Snapshots() ([]Snapshot, error) {
+	entries, err := s.fs.ReadDir(s.dir)
 	if err != nil {
-		return
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
 	}
-	var dirs []string
+	var snapshots []Snapshot
 	for _, entry := range entries {
-		if entry.IsDir() && len(entry.Name()) == 14 {
-			dirs = append(dirs, filepath.Join(baseDir, entry.Name()))
+		if !entry.IsDir() || len(entry.Name()) != len(SnapshotLayout) {
+			continue
+		}
+		t, err := time.ParseInLocation(SnapshotLayout, entry.Name(), time.Local)
+		if err != nil {
+			continue
 		}
+		snapshots = append(snapshots, Snapshot{
+			Name: entry.Name(),
+			Path: filepath.Join(s.dir, entry.Name()),
+			Time: t,
+		})
 	}
-	if len(dirs) <= keepMaxCount {
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Name < snapshots[j].Name })
+	return snapshots, nil
+}
+
+// Remove deletes a snapshot directory and its contents.
+func (s *Store) Remove(snapshot Snapshot) error {
+	return s.fs.RemoveAll(snapshot.Path)
+}
+
+func (s *Store) RemoveOutdatedBackups(keepMaxCount int) {
+	if keepMaxCount <= 0 {
 		return
 	}
-	// Sort descending (newest first)
-	for i := 0; i < len(dirs)-1; i++ {
-		for j := i + 1; j < len(dirs); j++ {
-			if dirs[i] < dirs[j] {
-				dirs[i], dirs[j] = dirs[j], dirs[i]
-			}
-		}
+	snapshots, err := s.Snapshots()
+	if err != nil || len(snapshots) <= keepMaxCount {
+		return
 	}
 	// Remove oldest
-	for _, dir := range dirs[keepMaxCount:] {
-		os.RemoveAll(dir)
+	for _, snapshot := range snapshots[:len(snapshots)-keepMaxCount] {
+		s.Remove(snapshot)
 	}
 }
-
-func ClearAll() error {
-	return os.RemoveAll(baseDir)
-}
@@ -0,0 +1,44 @@
+package backup
+
+import (
+	"testing"
+
+	"github.com/hidakatsuya/flexdot-go/internal/fsys"
+)
+
+func TestBackupFilePaths(t *testing.T) {
+	mem := fsys.NewMemFS()
+	for _, file := range []string{"/home/.vimrc", "/home/_abs/x", "/etc/foo/config", "/opt/bar/config"} {
+		if err := mem.WriteFile(file, []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store := NewStore(mem, "/backup")
+
+	for _, tt := range []struct{ file, rel string }{
+		{"/home/.vimrc", ".vimrc"},
+		{"/home/_abs/x", "_abs/home/_abs/x"},
+		{"/etc/foo/config", "_abs/etc/foo/config"},
+		{"/opt/bar/config", "_abs/opt/bar/config"},
+	} {
+		backupDir, err := store.BackupFile("/home", tt.file)
+		if err != nil {
+			t.Fatalf("BackupFile(%s) failed: %v", tt.file, err)
+		}
+		if data, err := mem.ReadFile(backupDir + "/" + tt.rel); err != nil || string(data) != tt.file {
+			t.Errorf("%s not backed up at %s: %q (%v)", tt.file, tt.rel, data, err)
+		}
+		if got := OriginalPath("/home", tt.rel); got != tt.file {
+			t.Errorf("OriginalPath(%s) = %s, want %s", tt.rel, got, tt.file)
+		}
+	}
+
+	// A second file at the same path does not replace the first backup
+	mem.WriteFile("/etc/foo/config", []byte("again"), 0644)
+	if _, err := store.BackupFile("/home", "/etc/foo/config"); err == nil {
+		t.Error("expected backing up the same path twice to fail")
+	}
+	if data, _ := mem.ReadFile("/backup/" + store.Snapshot() + "/_abs/etc/foo/config"); string(data) != "/etc/foo/config" {
+		t.Errorf("first backup was overwritten with %q", data)
+	}
+}
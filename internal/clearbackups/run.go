@@ -1,14 +1,133 @@
 package clearbackups
 
 import (
+	"bufio"
 	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/hidakatsuya/flexdot-go/internal/backup"
+	"github.com/hidakatsuya/flexdot-go/internal/fsys"
 )
 
-func Run() error {
-	if err := backup.ClearAll(); err != nil {
-		return fmt.Errorf("failed to clear backups: %w", err)
+type Options struct {
+	BackupDir string
+	OlderThan time.Duration // 0 disables the filter
+	Keep      int           // number of newest snapshots to keep, -1 disables the filter
+	Snapshot  string        // only this snapshot, empty disables the filter
+
+	Yes         bool // skip the confirmation
+	Interactive bool // whether In can be used to ask for confirmation
+	In          io.Reader
+	Out         io.Writer
+}
+
+func Run(opts Options) error {
+	store := backup.NewStore(fsys.OS, opts.BackupDir)
+	snapshots, err := store.Snapshots()
+	if err != nil {
+		return fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	targets, err := selectSnapshots(snapshots, opts, time.Now())
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		fmt.Fprintln(opts.Out, "No backups to delete.")
+		return nil
+	}
+
+	fmt.Fprintln(opts.Out, "Backups to delete:")
+	for _, snapshot := range targets {
+		fmt.Fprintf(opts.Out, "  %s\n", snapshot.Path)
+	}
+
+	if !opts.Yes {
+		if !opts.Interactive {
+			return fmt.Errorf("refusing to delete backups without confirmation; pass --yes")
+		}
+		if !confirm(opts.In, opts.Out, fmt.Sprintf("Delete %d backup(s)? [y/N]: ", len(targets))) {
+			return fmt.Errorf("aborted; no backups were deleted")
+		}
 	}
+
+	for _, snapshot := range targets {
+		if err := store.Remove(snapshot); err != nil {
+			return fmt.Errorf("failed to clear backups: %w", err)
+		}
+	}
+	fmt.Fprintf(opts.Out, "Deleted %d backup(s).\n", len(targets))
 	return nil
 }
+
+// selectSnapshots applies the filters of opts to snapshots (oldest first).
+func selectSnapshots(snapshots []backup.Snapshot, opts Options, now time.Time) ([]backup.Snapshot, error) {
+	if opts.Snapshot != "" {
+		var found []backup.Snapshot
+		for _, snapshot := range snapshots {
+			if snapshot.Name == opts.Snapshot {
+				found = append(found, snapshot)
+			}
+		}
+		if len(found) == 0 {
+			return nil, fmt.Errorf("backup snapshot not found: %s", opts.Snapshot)
+		}
+		snapshots = found
+	}
+
+	if opts.Keep >= 0 {
+		if opts.Keep >= len(snapshots) {
+			return nil, nil
+		}
+		snapshots = snapshots[:len(snapshots)-opts.Keep]
+	}
+
+	if opts.OlderThan > 0 {
+		threshold := now.Add(-opts.OlderThan)
+		var old []backup.Snapshot
+		for _, snapshot := range snapshots {
+			if snapshot.Time.Before(threshold) {
+				old = append(old, snapshot)
+			}
+		}
+		snapshots = old
+	}
+	return snapshots, nil
+}
+
+func confirm(in io.Reader, out io.Writer, prompt string) bool {
+	fmt.Fprint(out, prompt)
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
+
+// ParseAge parses durations such as "30d", "2w" or anything accepted by
+// time.ParseDuration.
+func ParseAge(s string) (time.Duration, error) {
+	units := map[string]time.Duration{
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+	}
+	for suffix, unit := range units {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			v, err := strconv.Atoi(n)
+			if err != nil || v < 0 {
+				return 0, fmt.Errorf("invalid age: %s", s)
+			}
+			return time.Duration(v) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age: %s", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,25 @@
+package clearbackups
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunDeclined(t *testing.T) {
+	for _, answer := range []string{"n\n", ""} {
+		dir := t.TempDir()
+		if err := os.MkdirAll(filepath.Join(dir, "20240101000000"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		err := Run(Options{BackupDir: dir, Keep: -1, Interactive: true, In: strings.NewReader(answer), Out: io.Discard})
+		if err == nil {
+			t.Errorf("answer %q: expected an error for a declined confirmation", answer)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "20240101000000")); err != nil {
+			t.Errorf("answer %q: the snapshot should be kept: %v", answer, err)
+		}
+	}
+}
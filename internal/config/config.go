@@ -3,42 +3,233 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
+	"github.com/hidakatsuya/flexdot-go/internal/backup"
+	"github.com/hidakatsuya/flexdot-go/internal/expand"
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
+	Version      int    `yaml:"version,omitempty"`
 	KeepMaxCount *int   `yaml:"keep_max_count"`
 	HomeDir      string `yaml:"home_dir"`
 	IndexYml     string `yaml:"index_yml"`
+	BackupDir    string `yaml:"backup_dir"`
+
+	AllowOutsideHome bool `yaml:"allow_outside_home,omitempty"`
+	DotPrefix        bool `yaml:"dot_prefix,omitempty"`
+	Strict           bool `yaml:"strict,omitempty"`
+
+	// Vars are looked up before the environment when expanding $VAR.
+	Vars map[string]string `yaml:"vars,omitempty"`
+
+	// Profile is the name of the active entry of Profiles, whose settings
+	// override the ones above.
+	Profile  string            `yaml:"profile,omitempty"`
+	Profiles map[string]Config `yaml:"profiles,omitempty"`
+
+	origins     map[string]string // layer name of each key that was set
+	lines       map[string]int    // line of each key in its layer file
+	fromProfile map[string]bool   // keys set by the active profile
 }
 
 func DefaultConfig() Config {
 	return Config{
+		Version:      CurrentVersion,
 		KeepMaxCount: ptrInt(10),
 		HomeDir:      "",
 		IndexYml:     "",
+		BackupDir:    "",
 	}
 }
 
 func ptrInt(v int) *int { return &v }
 
-func LoadConfig(dotfilesDir string) (*Config, error) {
-	path := dotfilesDir + "/config.yml"
-	f, err := os.Open(path)
+// profileSetting is a value of a profile and where it was set.
+type profileSetting struct {
+	value  any
+	origin string
+	line   int
+}
+
+// LoadConfig loads the layered configuration of dotfilesDir. See Layers for
+// the files and variables it reads; later layers override earlier ones key
+// by key. The settings of the named profile, or if profile is empty the one
+// selected by $FLEXDOT_PROFILE or the profile key, apply on top of the files
+// and below the environment variables.
+func LoadConfig(dotfilesDir, profile string) (*Config, error) {
+	cfg := &Config{
+		origins:     map[string]string{},
+		lines:       map[string]int{},
+		fromProfile: map[string]bool{},
+	}
+	merged := map[string]any{}
+	vars := map[string]string{}
+	profiles := map[string]map[string]profileSetting{}
+
+	for _, layer := range Layers(dotfilesDir) {
+		values, valueLines, err := layer.load()
+		if err != nil {
+			return nil, err
+		}
+
+		if layer.Path == "" {
+			// The profile overrides the files but not the environment
+			if err := cfg.applyProfile(profile, merged, vars, profiles); err != nil {
+				return nil, err
+			}
+		}
+		if layerProfiles, ok := values["profiles"].(map[string]any); ok {
+			for name, settings := range layerProfiles {
+				if profiles[name] == nil {
+					profiles[name] = map[string]profileSetting{}
+				}
+				settings, _ := settings.(map[string]any)
+				for key, value := range settings {
+					line := valueLines["profiles."+name+"."+key]
+					profiles[name][key] = profileSetting{value, layer.Name, line}
+				}
+			}
+			delete(values, "profiles")
+		}
+		if layerVars, ok := values["vars"].(map[string]any); ok {
+			for name, value := range layerVars {
+				vars[name] = fmt.Sprint(value)
+			}
+			delete(values, "vars")
+		}
+
+		for key, value := range values {
+			merged[key] = value
+			cfg.origins[key] = layer.Name
+			cfg.lines[key] = valueLines[key]
+			delete(cfg.fromProfile, key)
+		}
+	}
+
+	data, err := yaml.Marshal(merged)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to decode config: %w", err)
+	}
+	cfg.Vars = vars
+	if profile != "" {
+		cfg.Profile = profile
+		cfg.origins["profile"] = "--profile"
+	}
+	if err := cfg.expandPaths(); err != nil {
+		return nil, err
+	}
+	if err := cfg.validate(dotfilesDir); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// applyProfile merges the settings of the active profile into merged and
+// vars. The active profile is name, or $FLEXDOT_PROFILE, or the profile key.
+func (c *Config) applyProfile(name string, merged map[string]any, vars map[string]string, profiles map[string]map[string]profileSetting) error {
+	if name == "" {
+		name = os.Getenv(EnvPrefix + "PROFILE")
+	}
+	if name == "" {
+		name, _ = merged["profile"].(string)
+	}
+	if name == "" {
+		return nil
+	}
+
+	settings, ok := profiles[name]
+	if !ok {
+		names := make([]string, 0, len(profiles))
+		for n := range profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return fmt.Errorf("unknown profile %q: no profiles are defined", name)
 		}
-		return nil, fmt.Errorf("failed to open config.yml: %w", err)
+		return fmt.Errorf("unknown profile %q, expected one of: %s", name, strings.Join(names, ", "))
 	}
-	defer f.Close()
 
-	var cfg Config
-	if err := yaml.NewDecoder(f).Decode(&cfg); err != nil {
-		return nil, fmt.Errorf("failed to decode config.yml: %w", err)
+	for key, setting := range settings {
+		switch key {
+		case "version", "profile", "profiles":
+			return fmt.Errorf("%s:%d: %s cannot be set in profile %q", setting.origin, setting.line, key, name)
+		case "vars":
+			profileVars, _ := setting.value.(map[string]any)
+			for n, value := range profileVars {
+				vars[n] = fmt.Sprint(value)
+			}
+		default:
+			merged[key] = setting.value
+			c.origins[key] = setting.origin
+			c.lines[key] = setting.line
+			c.fromProfile[key] = true
+		}
 	}
-	return &cfg, nil
+	return nil
+}
+
+// Lookup returns the value of the variable name from Vars or the environment.
+func (c *Config) Lookup(name string) (string, bool) {
+	return expand.WithVars(c.GetVars())(name)
+}
+
+// expandPaths expands a leading ~ and environment variables in the path
+// settings.
+func (c *Config) expandPaths() error {
+	home, homeErr := os.UserHomeDir()
+	for _, setting := range []struct {
+		key   string
+		value *string
+	}{
+		{"home_dir", &c.HomeDir},
+		{"index_yml", &c.IndexYml},
+		{"backup_dir", &c.BackupDir},
+	} {
+		if homeErr != nil && strings.HasPrefix(*setting.value, "~") {
+			return c.errorf(setting.key, "%v", homeErr)
+		}
+		expanded, err := expand.PathFunc(*setting.value, home, c.Lookup)
+		if err != nil {
+			return c.errorf(setting.key, "%v", err)
+		}
+		*setting.value = expanded
+	}
+	return nil
+}
+
+// FindDotfilesDir returns the closest of dir and its parents that holds a
+// config.yml, or "" when there is none.
+func FindDotfilesDir(dir string) string {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "config.yml")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// Origin returns the name of the layer that set key, or "default". Values
+// set by a profile are marked with its name.
+func (c *Config) Origin(key string) string {
+	if c == nil || c.origins[key] == "" {
+		return "default"
+	}
+	if c.fromProfile[key] {
+		return fmt.Sprintf("%s (profile %s)", c.origins[key], c.Profile)
+	}
+	return c.origins[key]
 }
 
 func (c *Config) GetKeepMaxCount() int {
@@ -47,3 +238,34 @@ func (c *Config) GetKeepMaxCount() int {
 	}
 	return *c.KeepMaxCount
 }
+
+// GetBackupDir returns the backup directory, resolving a relative backup_dir
+// against dotfilesDir and defaulting to <dotfilesDir>/backup.
+func (c *Config) GetBackupDir(dotfilesDir string) string {
+	if c == nil || c.BackupDir == "" {
+		return backup.DefaultDir(dotfilesDir)
+	}
+	if filepath.IsAbs(c.BackupDir) {
+		return c.BackupDir
+	}
+	return filepath.Join(dotfilesDir, c.BackupDir)
+}
+
+func (c *Config) GetAllowOutsideHome() bool {
+	return c != nil && c.AllowOutsideHome
+}
+
+func (c *Config) GetDotPrefix() bool {
+	return c != nil && c.DotPrefix
+}
+
+func (c *Config) GetStrict() bool {
+	return c != nil && c.Strict
+}
+
+func (c *Config) GetVars() map[string]string {
+	if c == nil {
+		return nil
+	}
+	return c.Vars
+}
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SetValue sets key to the text value in the config file at path, creating
+// the file when it is missing. The rest of the file, including comments,
+// blank lines and key order, is kept as it is.
+func SetValue(path, key, raw string) error {
+	value, err := ParseValue(key, raw)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("invalid %s: %w", path, err)
+	}
+
+	node := scalarNode(value)
+	if doc.Kind == 0 {
+		// Empty or missing file
+		return os.WriteFile(path, appendKey(data, key, node), 0644)
+	}
+	mapping := doc.Content[0]
+	if mapping.Kind != yaml.MappingNode {
+		return fmt.Errorf("invalid %s: not a mapping", path)
+	}
+
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value != key {
+			continue
+		}
+		old := mapping.Content[i+1]
+		if edited, ok := replaceScalar(data, old, node); ok {
+			return os.WriteFile(path, edited, 0644)
+		}
+		// Multi-line values cannot be replaced in place; re-encode the
+		// document, which keeps the comments but not blank lines
+		node.HeadComment, node.LineComment, node.FootComment = old.HeadComment, old.LineComment, old.FootComment
+		mapping.Content[i+1] = node
+		return encodeDocument(path, &doc)
+	}
+
+	if mapping.Style&yaml.FlowStyle != 0 {
+		mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, node)
+		return encodeDocument(path, &doc)
+	}
+	return os.WriteFile(path, appendKey(data, key, node), 0644)
+}
+
+// scalarNode returns the YAML node of a value returned by ParseValue.
+func scalarNode(value any) *yaml.Node {
+	switch v := value.(type) {
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(v)}
+	case int:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(v)}
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: fmt.Sprint(value)}
+}
+
+// formatScalar returns the YAML text of a scalar node.
+func formatScalar(node *yaml.Node) (string, error) {
+	out, err := yaml.Marshal(node)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+// appendKey appends "key: value" to the block mapping in data.
+func appendKey(data []byte, key string, node *yaml.Node) []byte {
+	text, err := formatScalar(node)
+	if err != nil {
+		text = node.Value
+	}
+	if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+		data = append(data, '\n')
+	}
+	return append(data, fmt.Sprintf("%s: %s\n", key, text)...)
+}
+
+// replaceScalar replaces the text of the single-line scalar old in data with
+// node. It returns false when old cannot be located exactly.
+func replaceScalar(data []byte, old, node *yaml.Node) ([]byte, bool) {
+	if old.Kind != yaml.ScalarNode || old.Style&(yaml.LiteralStyle|yaml.FoldedStyle|yaml.TaggedStyle) != 0 {
+		return nil, false
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if old.Line < 1 || old.Line > len(lines) {
+		return nil, false
+	}
+	line := lines[old.Line-1]
+	start := old.Column - 1
+	if start < 0 || start > len(line) {
+		return nil, false
+	}
+
+	rest := line[start:]
+	var n int
+	switch {
+	case old.Style&yaml.DoubleQuotedStyle != 0:
+		n = closingQuote(rest, '"')
+	case old.Style&yaml.SingleQuotedStyle != 0:
+		n = closingQuote(rest, '\'')
+	default:
+		n = len(old.Value)
+		if !strings.HasPrefix(rest, old.Value) {
+			return nil, false
+		}
+	}
+	if n < 0 {
+		return nil, false
+	}
+
+	text, err := formatScalar(node)
+	if err != nil {
+		return nil, false
+	}
+	if start > 0 && line[start-1] == ':' {
+		// An empty value starts right after the colon
+		text = " " + text
+	}
+	lines[old.Line-1] = line[:start] + text + rest[n:]
+	return []byte(strings.Join(lines, "")), true
+}
+
+// closingQuote returns the length of the quoted scalar at the start of s,
+// including both quotes, or -1 when it does not end on this line.
+func closingQuote(s string, quote byte) int {
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '\\':
+			i++
+		case s[i] == quote:
+			if quote == '\'' && i+1 < len(s) && s[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// encodeDocument writes doc to path.
+func encodeDocument(path string, doc *yaml.Node) error {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
@@ -0,0 +1,218 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is the prefix of the environment variables overriding config
+// keys, e.g. FLEXDOT_HOME_DIR for home_dir.
+const EnvPrefix = "FLEXDOT_"
+
+// Layer is a source of config values.
+type Layer struct {
+	Name string // file path, or "env" for the environment
+	Path string // empty for the environment
+}
+
+// Layers returns the config sources of dotfilesDir from the lowest to the
+// highest precedence: the global $XDG_CONFIG_HOME/flexdot/config.yml, the
+// repo config.yml, the untracked config.local.yml and the FLEXDOT_*
+// environment variables.
+func Layers(dotfilesDir string) []Layer {
+	var layers []Layer
+	if path := GlobalPath(); path != "" {
+		layers = append(layers, Layer{Name: path, Path: path})
+	}
+	for _, name := range []string{"config.yml", "config.local.yml"} {
+		path := filepath.Join(dotfilesDir, name)
+		layers = append(layers, Layer{Name: path, Path: path})
+	}
+	return append(layers, Layer{Name: "env"})
+}
+
+// GlobalPath returns the path of the per-user config file, or "" when the
+// home directory is unknown.
+func GlobalPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "flexdot", "config.yml")
+}
+
+// load returns the values set by the layer and the line of each key in the
+// file. A missing file sets nothing.
+func (l Layer) load() (map[string]any, map[string]int, error) {
+	if l.Path == "" {
+		values, err := loadEnv()
+		return values, nil, err
+	}
+
+	data, err := os.ReadFile(l.Path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("failed to open %s: %w", l.Path, err)
+	}
+
+	// Decode once into Config to reject unknown keys and mistyped values
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var typed Config
+	if err := dec.Decode(&typed); err != nil && !errors.Is(err, io.EOF) {
+		return nil, nil, fmt.Errorf("invalid %s: %w", l.Path, err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("invalid %s: %w", l.Path, err)
+	}
+	values := map[string]any{}
+	lines := map[string]int{}
+	if len(doc.Content) > 0 {
+		mapping := doc.Content[0]
+		for i := 0; i+1 < len(mapping.Content); i += 2 {
+			key, value := mapping.Content[i], mapping.Content[i+1]
+			var v any
+			if err := value.Decode(&v); err != nil {
+				return nil, nil, fmt.Errorf("%s:%d: %w", l.Path, value.Line, err)
+			}
+			values[key.Value] = v
+			lines[key.Value] = key.Line
+			if key.Value == "profiles" {
+				recordProfileLines(value, lines)
+			}
+		}
+	}
+
+	if err := migrate(values); err != nil {
+		line := lines["version"]
+		return nil, nil, fmt.Errorf("%s:%d: %w", l.Path, line, err)
+	}
+	delete(values, "version")
+	return values, lines, nil
+}
+
+// recordProfileLines sets lines["profiles.<name>.<key>"] to the line of each
+// key of each profile in the mapping node profiles.
+func recordProfileLines(profiles *yaml.Node, lines map[string]int) {
+	for i := 0; i+1 < len(profiles.Content); i += 2 {
+		name, settings := profiles.Content[i].Value, profiles.Content[i+1]
+		for j := 0; j+1 < len(settings.Content); j += 2 {
+			key := settings.Content[j]
+			lines["profiles."+name+"."+key.Value] = key.Line
+		}
+	}
+}
+
+// loadEnv reads the FLEXDOT_<KEY> variables.
+func loadEnv() (map[string]any, error) {
+	values := map[string]any{}
+	for _, key := range Keys() {
+		name := EnvPrefix + strings.ToUpper(key)
+		raw, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		value, err := ParseValue(key, raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s=%q: %w", name, raw, err)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// ParseValue converts the text of a value of key, as given in an environment
+// variable or on the command line, to the type of key.
+func ParseValue(key, raw string) (any, error) {
+	if !slices.Contains(Keys(), key) {
+		return nil, fmt.Errorf("unknown key %q", key)
+	}
+	value, _ := (*Config)(nil).Get(key)
+	switch value.(type) {
+	case bool:
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be true or false", key)
+		}
+		return v, nil
+	case int:
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a number", key)
+		}
+		return v, nil
+	}
+	return raw, nil
+}
+
+// Keys returns the keys of the single-valued settings in the order of the
+// Config fields, leaving out the version of the file format, vars and
+// profiles.
+func Keys() []string {
+	var keys []string
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if key := yamlKey(field); key != "" && key != "version" && field.Type.Kind() != reflect.Map {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Get returns the effective value of key, falling back to the default, and
+// whether key exists.
+func (c *Config) Get(key string) (any, bool) {
+	defaults := DefaultConfig()
+	v := reflect.ValueOf(&defaults).Elem()
+	if c != nil {
+		v = reflect.ValueOf(c).Elem()
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if yamlKey(t.Field(i)) != key {
+			continue
+		}
+		field := v.Field(i)
+		if field.Kind() == reflect.Pointer {
+			if field.IsNil() {
+				field = reflect.ValueOf(defaults).Field(i)
+			}
+			field = field.Elem()
+		}
+		return field.Interface(), true
+	}
+	return nil, false
+}
+
+// yamlKey returns the yaml key of an exported field, or "".
+func yamlKey(f reflect.StructField) string {
+	if !f.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
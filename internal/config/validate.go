@@ -0,0 +1,95 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// CurrentVersion is the version of the config file format written by this
+// build. Files without a version are version 1.
+const CurrentVersion = 1
+
+// migrations upgrade the values of a config file from version n to n+1, e.g.
+// when a key is renamed. migrations[n] is applied to a version n file.
+var migrations = map[int]func(values map[string]any){}
+
+// migrate upgrades the values of a config file to CurrentVersion.
+func migrate(values map[string]any) error {
+	version := 1
+	if raw, ok := values["version"]; ok {
+		v, ok := raw.(int)
+		if !ok || v < 1 {
+			return fmt.Errorf("version must be a positive number, got %v", raw)
+		}
+		version = v
+	}
+	if version > CurrentVersion {
+		return fmt.Errorf("config version %d is newer than this flexdot supports (%d); please upgrade flexdot", version, CurrentVersion)
+	}
+	for ; version < CurrentVersion; version++ {
+		if m := migrations[version]; m != nil {
+			m(values)
+		}
+	}
+	return nil
+}
+
+// validate checks the ranges and paths of the merged config.
+func (c *Config) validate(dotfilesDir string) error {
+	if c.KeepMaxCount != nil && *c.KeepMaxCount < 0 {
+		return c.errorf("keep_max_count", "must be 0 or more, got %d", *c.KeepMaxCount)
+	}
+	if c.HomeDir != "" && !filepath.IsAbs(c.HomeDir) {
+		return c.errorf("home_dir", "must be an absolute path, got %q", c.HomeDir)
+	}
+	if c.IndexYml != "" && strings.HasSuffix(c.IndexYml, "/") {
+		return c.errorf("index_yml", "must be a file, got %q", c.IndexYml)
+	}
+	if c.BackupDir != "" {
+		// Old backups are deleted from backup_dir, so it must not hold the
+		// dotfiles themselves
+		backupDir, err := filepath.Abs(c.GetBackupDir(dotfilesDir))
+		if err != nil {
+			return c.errorf("backup_dir", "%v", err)
+		}
+		dir, err := filepath.Abs(dotfilesDir)
+		if err != nil {
+			return c.errorf("backup_dir", "%v", err)
+		}
+		if rel, err := filepath.Rel(backupDir, dir); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return c.errorf("backup_dir", "must not contain the dotfiles directory, got %q", c.BackupDir)
+		}
+	}
+	return nil
+}
+
+// Validate checks c as LoadConfig checks the config files, expanding a
+// leading ~ and environment variables in a copy of its paths first. It is
+// meant for values about to be written to a config file.
+func (c *Config) Validate(dotfilesDir string) error {
+	cfg := *c
+	if err := cfg.expandPaths(); err != nil {
+		return err
+	}
+	return cfg.validate(dotfilesDir)
+}
+
+// errorf returns an error about key, located at the file and line or the
+// environment variable that set it, if any, and the profile it was set by.
+func (c *Config) errorf(key, format string, args ...any) error {
+	where := c.origins[key]
+	switch {
+	case where == "":
+		// Not read from a layer, e.g. a value checked by Validate
+		return fmt.Errorf("%s %s", key, fmt.Sprintf(format, args...))
+	case where == "env":
+		where = EnvPrefix + strings.ToUpper(key)
+	case c.lines[key] > 0:
+		where = fmt.Sprintf("%s:%d", where, c.lines[key])
+	}
+	if c.fromProfile[key] {
+		where = fmt.Sprintf("%s (profile %s)", where, c.Profile)
+	}
+	return fmt.Errorf("%s: %s %s", where, key, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,78 @@
+// Package configcmd implements the config command.
+package configcmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/hidakatsuya/flexdot-go/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// Show prints the effective value of every config key as YAML. With origin
+// set, each line is prefixed with the file or "env" that set the value, or
+// "default".
+func Show(w io.Writer, cfg *config.Config, origin bool) error {
+	for _, key := range config.Keys() {
+		value, _ := cfg.Get(key)
+		line, err := yaml.Marshal(map[string]any{key: value})
+		if err != nil {
+			return err
+		}
+		if origin {
+			fmt.Fprintf(w, "%s\t", cfg.Origin(key))
+		}
+		fmt.Fprintln(w, strings.TrimSuffix(string(line), "\n"))
+	}
+	return nil
+}
+
+// Get prints the effective value of key.
+func Get(w io.Writer, cfg *config.Config, key string) error {
+	if !slices.Contains(config.Keys(), key) {
+		return fmt.Errorf("unknown key %q", key)
+	}
+	value, _ := cfg.Get(key)
+	fmt.Fprintln(w, value)
+	return nil
+}
+
+// List prints the effective value of every config key as key=value.
+func List(w io.Writer, cfg *config.Config) {
+	for _, key := range config.Keys() {
+		value, _ := cfg.Get(key)
+		fmt.Fprintf(w, "%s=%v\n", key, value)
+	}
+}
+
+// Set writes key to the config file at path and checks that the layered
+// config of dotfilesDir is still valid, restoring the file otherwise.
+func Set(dotfilesDir, path, key, value string) error {
+	original, err := os.ReadFile(path)
+	existed := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	if err := config.SetValue(path, key, value); err != nil {
+		return err
+	}
+	if _, err := config.LoadConfig(dotfilesDir, ""); err != nil {
+		if existed {
+			os.WriteFile(path, original, 0644)
+		} else {
+			os.Remove(path)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+// Package diff produces unified diffs of text files, as shown when a home
+// file conflicts with its dotfile.
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// context is the number of unchanged lines shown around each change.
+const context = 3
+
+// sniffLen is how much of a file IsBinary looks at, as git does.
+const sniffLen = 8000
+
+// maxEdits bounds the number of changed lines Unified computes a diff for;
+// the memory of the search grows with its square.
+const maxEdits = 1000
+
+// IsBinary reports whether data looks like the content of a binary file:
+// it has a NUL byte near its start.
+func IsBinary(data []byte) bool {
+	return bytes.IndexByte(data[:min(len(data), sniffLen)], 0) >= 0
+}
+
+// Files returns the unified diff turning a into b, or a note when either
+// is binary, and "" when they are equal.
+func Files(aName, bName string, a, b []byte) string {
+	if string(a) == string(b) {
+		return ""
+	}
+	if IsBinary(a) || IsBinary(b) {
+		return fmt.Sprintf("Binary files %s and %s differ\n", aName, bName)
+	}
+	return Unified(aName, bName, a, b)
+}
+
+// edit is a line of the diff: ' ' for a line of both files, '-' for a line
+// only in a and '+' for a line only in b. aPos and bPos are the indexes of
+// the line in a and b, or of the next line for the file without it.
+type edit struct {
+	kind       byte
+	aPos, bPos int
+}
+
+// Unified returns the unified diff turning a into b, with aName and bName in
+// the header, or "" when they are equal. When more than maxEdits lines
+// change it returns a note that the files differ instead.
+func Unified(aName, bName string, a, b []byte) string {
+	if string(a) == string(b) {
+		return ""
+	}
+	aLines, bLines := splitLines(string(a)), splitLines(string(b))
+	edits, ok := lineEdits(aLines, bLines, maxEdits)
+	if !ok {
+		return fmt.Sprintf("Files %s and %s differ (too many changes to show)\n", aName, bName)
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+	for i := 0; i < len(edits); {
+		// Skip to the next change
+		for i < len(edits) && edits[i].kind == ' ' {
+			i++
+		}
+		if i == len(edits) {
+			break
+		}
+
+		// Extend the hunk while the changes are close enough to share context
+		start := max(0, i-context)
+		last := i
+		for j := i; j < len(edits) && j-last <= 2*context; j++ {
+			if edits[j].kind != ' ' {
+				last = j
+			}
+		}
+		end := min(len(edits), last+context+1)
+		writeHunk(&out, edits[start:end], aLines, bLines)
+		i = end
+	}
+	return out.String()
+}
+
+// writeHunk writes the header and lines of a hunk.
+func writeHunk(out *strings.Builder, hunk []edit, a, b []string) {
+	aCount, bCount := 0, 0
+	for _, e := range hunk {
+		if e.kind != '+' {
+			aCount++
+		}
+		if e.kind != '-' {
+			bCount++
+		}
+	}
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(hunk[0].aPos, aCount), hunkRange(hunk[0].bPos, bCount))
+	for _, e := range hunk {
+		line := ""
+		switch e.kind {
+		case '+':
+			line = b[e.bPos]
+		default:
+			line = a[e.aPos]
+		}
+		out.WriteByte(e.kind)
+		out.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// hunkRange formats the 0-based start and line count of a hunk.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		// An empty range names the line before it
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// splitLines splits s after each newline.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// lineEdits returns the shortest edit script from a to b, using the Myers
+// algorithm, or false when it needs more than limit insertions and
+// deletions.
+func lineEdits(a, b []string, limit int) ([]edit, bool) {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+
+	// trace[d] keeps the diagonals -d-1 to d+1 of v before step d, the
+	// only ones the walk back reads
+	var trace [][]int
+
+search:
+	for d := 0; ; d++ {
+		if d > limit {
+			return nil, false
+		}
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// Walk the trace back from the end, collecting the edits in reverse
+	var edits []edit
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || k != d && v[d+k] < v[d+k+2] {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[d+1+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, edit{' ', x, y})
+		}
+		if d > 0 {
+			if x == prevX {
+				y--
+				edits = append(edits, edit{'+', x, y})
+			} else {
+				x--
+				edits = append(edits, edit{'-', x, y})
+			}
+		}
+	}
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits, true
+}
@@ -0,0 +1,89 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{"equal", "a\nb\n", "a\nb\n", ""},
+		{
+			name: "change",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			b:    "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			want: "--- a\n+++ b\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "separate hunks",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			b:    "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n",
+			want: "--- a\n+++ b\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -10,3 +10,4 @@\n 10\n 11\n 12\n+13\n",
+		},
+		{
+			name: "empty file",
+			a:    "",
+			b:    "x\n",
+			want: "--- a\n+++ b\n@@ -0,0 +1 @@\n+x\n",
+		},
+		{
+			name: "missing newline",
+			a:    "x\ny",
+			b:    "x\ny\n",
+			want: "--- a\n+++ b\n@@ -1,2 +1,2 @@\n x\n-y\n\\ No newline at end of file\n+y\n",
+		},
+	}
+	for _, tt := range tests {
+		if got := Unified("a", "b", []byte(tt.a), []byte(tt.b)); got != tt.want {
+			t.Errorf("%s: Unified =\n%s\nwant:\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUnifiedLargeFiles(t *testing.T) {
+	var a, b, c strings.Builder
+	for i := 0; i < 100000; i++ {
+		fmt.Fprintf(&a, "line %d\n", i)
+		fmt.Fprintf(&c, "other %d\n", i)
+		if i == 50000 {
+			b.WriteString("changed\n")
+		} else {
+			fmt.Fprintf(&b, "line %d\n", i)
+		}
+	}
+
+	want := "--- a\n+++ b\n@@ -49998,7 +49998,7 @@\n line 49997\n line 49998\n line 49999\n-line 50000\n+changed\n line 50001\n line 50002\n line 50003\n"
+	if got := Unified("a", "b", []byte(a.String()), []byte(b.String())); got != want {
+		t.Errorf("Unified of a small change =\n%s\nwant:\n%s", got, want)
+	}
+	want = "Files a and c differ (too many changes to show)\n"
+	if got := Unified("a", "c", []byte(a.String()), []byte(c.String())); got != want {
+		t.Errorf("Unified of unrelated files = %q, want %q", got, want)
+	}
+}
+
+func TestFiles(t *testing.T) {
+	if got := Files("a", "b", []byte("x\n"), []byte("x\n")); got != "" {
+		t.Errorf("Files of equal content = %q, want \"\"", got)
+	}
+	if got, want := Files("a", "b", []byte("x\n"), []byte("x\x00")), "Binary files a and b differ\n"; got != want {
+		t.Errorf("Files of binary content = %q, want %q", got, want)
+	}
+	if got, want := Files("a", "b", []byte("x\n"), []byte("y\n")), Unified("a", "b", []byte("x\n"), []byte("y\n")); got != want {
+		t.Errorf("Files of text content = %q, want %q", got, want)
+	}
+}
+
+func TestIsBinary(t *testing.T) {
+	if IsBinary([]byte("set nu\n")) {
+		t.Errorf("text detected as binary")
+	}
+	if !IsBinary([]byte("\x89PNG\r\n\x1a\n\x00\x00")) {
+		t.Errorf("binary not detected")
+	}
+}
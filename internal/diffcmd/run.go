@@ -0,0 +1,100 @@
+package diffcmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hidakatsuya/flexdot-go/internal/diff"
+	"github.com/hidakatsuya/flexdot-go/internal/fsys"
+	"github.com/hidakatsuya/flexdot-go/internal/install"
+)
+
+// DifferError is returned by Run when every file could be compared and some
+// of them differ.
+type DifferError struct {
+	Count int
+}
+
+func (e *DifferError) Error() string {
+	if e.Count == 1 {
+		return "1 file differs"
+	}
+	return fmt.Sprintf("%d files differ", e.Count)
+}
+
+// Run prints, for every entry whose home file install would back up, the
+// diff from the dotfile to the home file, without changing anything. Like
+// diff(1), it returns a *DifferError when files differ and another error when
+// any of them could not be compared.
+func Run(opts install.Options) error {
+	if opts.Warn == nil {
+		opts.Warn = install.OutputWarning
+	}
+	fs := fsys.Or(opts.FS)
+	installer := install.New(opts)
+	entries, err := installer.Entries()
+	if err != nil {
+		return err
+	}
+
+	steps, planErr := installer.Plan(entries)
+	differ, failed := 0, 0
+	for _, step := range steps {
+		if step.Action != install.ActionReplace {
+			continue
+		}
+		relPath, err := filepath.Rel(opts.HomeDir, step.HomeFile)
+		if err != nil {
+			relPath = step.HomeFile
+		}
+		d, err := fileDiff(fs, step, relPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			failed++
+			continue
+		}
+		if d != "" {
+			fmt.Print(d)
+			differ++
+		}
+	}
+	if planErr != nil {
+		for _, err := range planErr.Errors {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		failed += len(planErr.Errors)
+	}
+
+	if failed == 1 {
+		return fmt.Errorf("1 file could not be compared")
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d files could not be compared", failed)
+	}
+	if differ > 0 {
+		return &DifferError{Count: differ}
+	}
+	return nil
+}
+
+// fileDiff returns the diff from the dotfile of step to the home file, or ""
+// when they are the same.
+func fileDiff(fs fsys.FS, step install.Step, relPath string) (string, error) {
+	fi, err := fs.Stat(step.Dotfile)
+	if err != nil {
+		return "", err
+	}
+	if fi.IsDir() {
+		return fmt.Sprintf("%s is a directory, %s is a file\n", step.Entry.DotfilePath, relPath), nil
+	}
+	repo, err := fs.ReadFile(step.Dotfile)
+	if err != nil {
+		return "", err
+	}
+	home, err := fs.ReadFile(step.HomeFile)
+	if err != nil {
+		return "", err
+	}
+	return diff.Files(step.Entry.DotfilePath, relPath, repo, home), nil
+}
@@ -0,0 +1,188 @@
+package e2e
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAddMovesFileIntoRepo(t *testing.T) {
+	workDir := t.TempDir()
+	bin := buildFlexdot(t, workDir)
+
+	homeDir := filepath.Join(workDir, "home")
+	dotfilesDir := filepath.Join(workDir, "dotfiles")
+	index := "# shared\ncommon:\n  vim:\n    .vimrc: .\n\n  # tools\n  foo:\n    other.toml: .config/foo\n"
+	writeFiles(t, workDir, map[string]string{
+		"dotfiles/common/vim/.vimrc":       "set nu",
+		"dotfiles/common/foo/other.toml":   "a = 1",
+		"dotfiles/index.yml":               index,
+		"dotfiles/config.yml":              "index_yml: index.yml\nhome_dir: " + homeDir + "\n",
+		"home/.config/foo/bar.toml":        "b = 2",
+		"home/.config/foo/already.toml":    "c = 3",
+		"dotfiles/common/foo/already.toml": "c = 0",
+	})
+
+	// The path may come before --to, as in the README
+	homeFile := filepath.Join(homeDir, ".config", "foo", "bar.toml")
+	cmd := flexdotCommand(bin, "add", homeFile, "--to", "common/foo")
+	cmd.Dir = dotfilesDir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("flexdot add failed: %v\nOutput: %s", err, string(out))
+	}
+	if !strings.Contains(string(out), "link created:") || !strings.Contains(string(out), filepath.Join(".config", "foo", "bar.toml")) {
+		t.Errorf("expected a link created log, got: %s", string(out))
+	}
+
+	dotfile := filepath.Join(dotfilesDir, "common", "foo", "bar.toml")
+	if data, err := os.ReadFile(dotfile); err != nil || string(data) != "b = 2" {
+		t.Errorf("file was not moved into the repo: %v %q", err, data)
+	}
+	if dest, err := os.Readlink(homeFile); err != nil || dest != dotfile {
+		t.Errorf("expected %s to link to %s, got %q (%v)", homeFile, dotfile, dest, err)
+	}
+	data, err := os.ReadFile(filepath.Join(dotfilesDir, "index.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# shared\ncommon:\n  vim:\n    .vimrc: .\n\n  # tools\n  foo:\n    other.toml: .config/foo\n    bar.toml: .config/foo\n"
+	if string(data) != want {
+		t.Errorf("index.yml =\n%s\nwant:\n%s", data, want)
+	}
+
+	// A file already in the repo is left alone
+	already := filepath.Join(homeDir, ".config", "foo", "already.toml")
+	cmd = flexdotCommand(bin, "add", "--to", "common/foo", already)
+	cmd.Dir = dotfilesDir
+	out, err = cmd.CombinedOutput()
+	if err == nil || !strings.Contains(string(out), "already exists") {
+		t.Errorf("expected add to fail for an existing dotfile, got: %v %s", err, string(out))
+	}
+	if fi, err := os.Lstat(already); err != nil || !fi.Mode().IsRegular() {
+		t.Errorf("%s should be left in place: %v", already, err)
+	}
+
+	// Status sees the new entry as linked
+	cmd = flexdotCommand(bin, "status")
+	cmd.Dir = dotfilesDir
+	out, _ = cmd.CombinedOutput()
+	if !strings.Contains(string(out), "\033[90mlinked:\033[0m "+filepath.Join(".config", "foo", "bar.toml")) {
+		t.Errorf("expected the added entry to be linked, got: %s", string(out))
+	}
+
+	// Files outside the home directory are rejected
+	outside := filepath.Join(workDir, "outside.txt")
+	if err := os.WriteFile(outside, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd = flexdotCommand(bin, "add", "--to", "common", outside)
+	cmd.Dir = dotfilesDir
+	if out, err := cmd.CombinedOutput(); err == nil || !strings.Contains(string(out), "outside the home directory") {
+		t.Errorf("expected add to reject a file outside home, got: %v %s", err, string(out))
+	}
+
+	// A path through a symlink leaving the home directory is rejected
+	// before anything is moved
+	outsideDir := filepath.Join(workDir, "elsewhere")
+	if err := os.MkdirAll(outsideDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	escaped := filepath.Join(outsideDir, "escaped.txt")
+	if err := os.WriteFile(escaped, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outsideDir, filepath.Join(homeDir, "elsewhere")); err != nil {
+		t.Fatal(err)
+	}
+	cmd = flexdotCommand(bin, "add", "--to", "common", filepath.Join(homeDir, "elsewhere", "escaped.txt"))
+	cmd.Dir = dotfilesDir
+	if out, err := cmd.CombinedOutput(); err == nil || !strings.Contains(string(out), "outside the home directory") {
+		t.Errorf("expected add to reject a path leaving home, got: %v %s", err, string(out))
+	}
+	if fi, err := os.Lstat(escaped); err != nil || !fi.Mode().IsRegular() {
+		t.Errorf("%s should be left in place: %v", escaped, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dotfilesDir, "index.yml")); err != nil || string(data) != want {
+		t.Errorf("index.yml should be left as it was, got:\n%s", data)
+	}
+}
+
+func TestAddRejectsDuplicateOfWildcard(t *testing.T) {
+	workDir := t.TempDir()
+	bin := buildFlexdot(t, workDir)
+
+	homeDir := filepath.Join(workDir, "home")
+	dotfilesDir := filepath.Join(workDir, "dotfiles")
+	index := "apps:\n  \"*/config.toml\": .config/{1}\n"
+	writeFiles(t, workDir, map[string]string{
+		"dotfiles/apps/foo/config.toml": "a = 1",
+		"dotfiles/index.yml":            index,
+		"dotfiles/config.yml":           "index_yml: index.yml\nhome_dir: " + homeDir + "\n",
+		"home/.config/baz/config.toml":  "b = 2",
+	})
+
+	// The wildcard would also link the added file to the same place
+	homeFile := filepath.Join(homeDir, ".config", "baz", "config.toml")
+	cmd := flexdotCommand(bin, "add", "--to", "apps/baz", homeFile)
+	cmd.Dir = dotfilesDir
+	out, err := cmd.CombinedOutput()
+	if err == nil || !strings.Contains(string(out), "entries link the same destination") {
+		t.Errorf("expected add to fail with a duplicate destination, got: %v %s", err, string(out))
+	}
+	if fi, err := os.Lstat(homeFile); err != nil || !fi.Mode().IsRegular() {
+		t.Errorf("%s should be left in place: %v", homeFile, err)
+	}
+	if _, err := os.Lstat(filepath.Join(dotfilesDir, "apps", "baz")); err == nil {
+		t.Errorf("nothing should be created in the dotfiles directory")
+	}
+	if data, err := os.ReadFile(filepath.Join(dotfilesDir, "index.yml")); err != nil || string(data) != index {
+		t.Errorf("index.yml should be left as it was, got:\n%s", data)
+	}
+
+	// Adding it elsewhere is fine
+	cmd = flexdotCommand(bin, "add", "--to", "other", homeFile)
+	cmd.Dir = dotfilesDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("flexdot add failed: %v\n%s", err, string(out))
+	}
+}
+
+func TestAddEscapesWildcardSyntax(t *testing.T) {
+	workDir := t.TempDir()
+	bin := buildFlexdot(t, workDir)
+
+	homeDir := filepath.Join(workDir, "home")
+	dotfilesDir := filepath.Join(workDir, "dotfiles")
+	writeFiles(t, workDir, map[string]string{
+		"dotfiles/index.yml":       "",
+		"dotfiles/config.yml":      "index_yml: index.yml\nhome_dir: " + homeDir + "\n",
+		"dotfiles/x/foo1.toml":     "other",
+		"home/.config/foo[1].toml": "a = 1",
+	})
+
+	homeFile := filepath.Join(homeDir, ".config", "foo[1].toml")
+	cmd := flexdotCommand(bin, "add", "--to", "x", homeFile)
+	cmd.Dir = dotfilesDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("flexdot add failed: %v\n%s", err, string(out))
+	}
+	data, err := os.ReadFile(filepath.Join(dotfilesDir, "index.yml"))
+	if err != nil || !strings.Contains(string(data), `foo\[1].toml`) {
+		t.Errorf("expected an escaped key in index.yml, got:\n%s (%v)", data, err)
+	}
+
+	// The entry links only the added file
+	cmd = flexdotCommand(bin, "install")
+	cmd.Dir = dotfilesDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("flexdot install failed: %v\n%s", err, string(out))
+	}
+	if dest, err := os.Readlink(homeFile); err != nil || dest != filepath.Join(dotfilesDir, "x", "foo[1].toml") {
+		t.Errorf("expected %s to link to the added file, got %q (%v)", homeFile, dest, err)
+	}
+	if _, err := os.Lstat(filepath.Join(homeDir, ".config", "foo1.toml")); err == nil {
+		t.Errorf("foo1.toml should not be linked")
+	}
+}
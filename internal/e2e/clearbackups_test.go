@@ -0,0 +1,119 @@
+package e2e
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// prepareSnapshots creates backup snapshot directories in dotfilesDir/backup.
+func prepareSnapshots(t *testing.T, dotfilesDir string, names ...string) string {
+	backupDir := filepath.Join(dotfilesDir, "backup")
+	for _, name := range names {
+		if err := os.MkdirAll(filepath.Join(backupDir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(backupDir, name, "myfile.txt"), []byte("old"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return backupDir
+}
+
+func remainingSnapshots(t *testing.T, backupDir string) []string {
+	entries, err := os.ReadDir(backupDir)
+	if err != nil {
+		t.Fatalf("failed to read backup dir: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestClearBackupsRequiresConfirmation(t *testing.T) {
+	workDir := t.TempDir()
+	bin := buildFlexdot(t, workDir)
+
+	dotfilesDir := filepath.Join(workDir, "dotfiles")
+	backupDir := prepareSnapshots(t, dotfilesDir, "20240101000000")
+
+	// stdin is not a terminal, so even a "y" answer is not accepted
+	cmd := flexdotCommand(bin, "clear-backups")
+	cmd.Dir = dotfilesDir
+	cmd.Stdin = strings.NewReader("y\n")
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected clear-backups to fail without --yes, got:\n%s", string(out))
+	}
+	if want := "--yes"; !strings.Contains(string(out), want) {
+		t.Errorf("expected output to contain %q, got: %s", want, string(out))
+	}
+	if !strings.Contains(string(out), "20240101000000") {
+		t.Errorf("expected the snapshot to be listed, got: %s", string(out))
+	}
+	if got := remainingSnapshots(t, backupDir); len(got) != 1 {
+		t.Errorf("snapshots should be kept without confirmation, got %v", got)
+	}
+
+	// Neither is /dev/null, as under cron or systemd
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+	cmd = flexdotCommand(bin, "clear-backups")
+	cmd.Dir = dotfilesDir
+	cmd.Stdin = devNull
+	out, err = cmd.CombinedOutput()
+	if err == nil || !strings.Contains(string(out), "--yes") {
+		t.Errorf("expected clear-backups to refuse with stdin from %s, got: %v\n%s", os.DevNull, err, string(out))
+	}
+}
+
+func TestClearBackupsFilters(t *testing.T) {
+	workDir := t.TempDir()
+	bin := buildFlexdot(t, workDir)
+
+	recent := time.Now().Add(-time.Hour).Format("20060102150405")
+	dotfilesDir := filepath.Join(workDir, "dotfiles")
+	backupDir := prepareSnapshots(t, dotfilesDir, "20240101000000", "20240102000000", "20240103000000", recent)
+
+	// A directory not following the snapshot naming scheme is never deleted
+	if err := os.MkdirAll(filepath.Join(backupDir, "keepme"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(args ...string) {
+		t.Helper()
+		cmd := flexdotCommand(bin, append([]string{"clear-backups", "--yes"}, args...)...)
+		cmd.Dir = dotfilesDir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("flexdot clear-backups %v failed: %v\n%s", args, err, string(out))
+		}
+	}
+
+	run("--snapshot", "20240102000000")
+	if got, want := strings.Join(remainingSnapshots(t, backupDir), ","), "20240101000000,20240103000000,"+recent+",keepme"; got != want {
+		t.Errorf("after --snapshot got %s, want %s", got, want)
+	}
+
+	run("--older-than", "30d")
+	if got, want := strings.Join(remainingSnapshots(t, backupDir), ","), recent+",keepme"; got != want {
+		t.Errorf("after --older-than got %s, want %s", got, want)
+	}
+
+	run("--keep", "1")
+	if got, want := strings.Join(remainingSnapshots(t, backupDir), ","), recent+",keepme"; got != want {
+		t.Errorf("after --keep got %s, want %s", got, want)
+	}
+
+	run()
+	if got, want := strings.Join(remainingSnapshots(t, backupDir), ","), "keepme"; got != want {
+		t.Errorf("after clearing all got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,208 @@
+package e2e
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigLayers(t *testing.T) {
+	workDir := t.TempDir()
+	bin := buildFlexdot(t, workDir)
+
+	// Prepare the global, repo and local config files
+	xdgDir := filepath.Join(workDir, "xdg")
+	globalYml := filepath.Join(xdgDir, "flexdot", "config.yml")
+	if err := os.MkdirAll(filepath.Dir(globalYml), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(globalYml, []byte("keep_max_count: 3\nstrict: true\nhome_dir: /global/home\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dotfilesDir := filepath.Join(workDir, "dotfiles")
+	if err := os.MkdirAll(dotfilesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	repoYml := filepath.Join(dotfilesDir, "config.yml")
+	if err := os.WriteFile(repoYml, []byte("index_yml: index.yml\nhome_dir: /repo/home\nstrict: false\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	localYml := filepath.Join(dotfilesDir, "config.local.yml")
+	if err := os.WriteFile(localYml, []byte("home_dir: /local/home\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(env ...string) string {
+		t.Helper()
+		cmd := flexdotCommand(bin, "config", "show", "--origin")
+		cmd.Dir = dotfilesDir
+		cmd.Env = append(cmd.Env, append([]string{"XDG_CONFIG_HOME=" + xdgDir}, env...)...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("flexdot config show failed: %v\n%s", err, string(out))
+		}
+		return string(out)
+	}
+
+	out := run()
+	for _, want := range []string{
+		globalYml + "\tkeep_max_count: 3",
+		repoYml + "\tstrict: false",
+		repoYml + "\tindex_yml: index.yml",
+		localYml + "\thome_dir: /local/home",
+		"default\tdot_prefix: false",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	// FLEXDOT_* variables override the files
+	out = run("FLEXDOT_HOME_DIR=/env/home", "FLEXDOT_KEEP_MAX_COUNT=5")
+	for _, want := range []string{"env\thome_dir: /env/home", "env\tkeep_max_count: 5"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestConfigValidation(t *testing.T) {
+	workDir := t.TempDir()
+	bin := buildFlexdot(t, workDir)
+
+	dotfilesDir := filepath.Join(workDir, "dotfiles")
+	if err := os.MkdirAll(dotfilesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	configYml := filepath.Join(dotfilesDir, "config.yml")
+
+	tests := []struct {
+		config string
+		env    []string
+		want   string
+	}{
+		{"home_dir: /home/me\nkeep_max_cout: 3\n", nil, "line 2: field keep_max_cout not found"},
+		{"keep_max_count: many\n", nil, "line 1: cannot unmarshal"},
+		{"version: 1\n\nkeep_max_count: -1\n", nil, "config.yml:3: keep_max_count must be 0 or more"},
+		{"home_dir: relative/home\n", nil, "config.yml:1: home_dir must be an absolute path"},
+		{"backup_dir: .\n", nil, "config.yml:1: backup_dir must not contain the dotfiles directory"},
+		{"profile: work\nprofiles:\n  work:\n    keep_max_count: -1\n", nil, "config.yml:4 (profile work): keep_max_count must be 0 or more"},
+		{"version: 99\n", nil, "config.yml:1: config version 99 is newer"},
+		{"", []string{"FLEXDOT_KEEP_MAX_COUNT=-2"}, "FLEXDOT_KEEP_MAX_COUNT: keep_max_count must be 0 or more"},
+		{"", []string{"FLEXDOT_STRICT=sometimes"}, "FLEXDOT_STRICT=\"sometimes\": strict must be true or false"},
+	}
+	for _, tt := range tests {
+		if err := os.WriteFile(configYml, []byte(tt.config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cmd := flexdotCommand(bin, "config", "show")
+		cmd.Dir = dotfilesDir
+		cmd.Env = append(cmd.Env, tt.env...)
+		out, err := cmd.CombinedOutput()
+		if err == nil {
+			t.Errorf("%q %v: expected config show to fail, got:\n%s", tt.config, tt.env, out)
+			continue
+		}
+		if !strings.Contains(string(out), tt.want) {
+			t.Errorf("%q %v: expected error to contain %q, got:\n%s", tt.config, tt.env, tt.want, out)
+		}
+	}
+
+	// A current version with valid values is accepted
+	if err := os.WriteFile(configYml, []byte("version: 1\nkeep_max_count: 0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := flexdotCommand(bin, "config", "show")
+	cmd.Dir = dotfilesDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("expected a valid config to load: %v\n%s", err, out)
+	}
+}
+
+func TestConfigGetSetList(t *testing.T) {
+	workDir := t.TempDir()
+	bin := buildFlexdot(t, workDir)
+
+	dotfilesDir := filepath.Join(workDir, "dotfiles")
+	if err := os.MkdirAll(dotfilesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	configYml := filepath.Join(dotfilesDir, "config.yml")
+	original := `# Shared settings
+keep_max_count: 10 # backups to keep
+index_yml: macOS.yml
+
+# Link dot_ files
+dot_prefix: false
+`
+	if err := os.WriteFile(configYml, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(args ...string) (string, error) {
+		t.Helper()
+		cmd := flexdotCommand(bin, append([]string{"config"}, args...)...)
+		cmd.Dir = dotfilesDir
+		out, err := cmd.CombinedOutput()
+		return string(out), err
+	}
+
+	for _, args := range [][]string{
+		{"set", "keep_max_count", "3"},
+		{"set", "dot_prefix", "true"},
+		{"set", "strict", "true"},
+		{"set", "--local", "home_dir", "/local/home"},
+	} {
+		if out, err := run(args...); err != nil {
+			t.Fatalf("flexdot config %v failed: %v\n%s", args, err, out)
+		}
+	}
+
+	data, err := os.ReadFile(configYml)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# Shared settings
+keep_max_count: 3 # backups to keep
+index_yml: macOS.yml
+
+# Link dot_ files
+dot_prefix: true
+strict: true
+`
+	if string(data) != want {
+		t.Errorf("unexpected config.yml:\n%s\nwant:\n%s", data, want)
+	}
+	if data, err := os.ReadFile(filepath.Join(dotfilesDir, "config.local.yml")); err != nil || string(data) != "home_dir: /local/home\n" {
+		t.Errorf("unexpected config.local.yml: %q, %v", data, err)
+	}
+
+	if out, err := run("get", "home_dir"); err != nil || out != "/local/home\n" {
+		t.Errorf("config get home_dir: got %q, %v", out, err)
+	}
+	out, err := run("list")
+	if err != nil {
+		t.Fatalf("flexdot config list failed: %v\n%s", err, out)
+	}
+	for _, line := range []string{"keep_max_count=3", "home_dir=/local/home", "index_yml=macOS.yml", "backup_dir=", "strict=true"} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("expected config list to contain %q, got:\n%s", line, out)
+		}
+	}
+
+	// Invalid keys and values are rejected and leave the file unchanged
+	for _, args := range [][]string{
+		{"set", "keep_max_cout", "3"},
+		{"set", "keep_max_count", "many"},
+		{"set", "keep_max_count", "-1"},
+		{"get", "unknown"},
+	} {
+		if out, err := run(args...); err == nil {
+			t.Errorf("expected flexdot config %v to fail, got:\n%s", args, out)
+		}
+	}
+	if data, _ := os.ReadFile(configYml); string(data) != want {
+		t.Errorf("config.yml changed by invalid sets:\n%s", data)
+	}
+}
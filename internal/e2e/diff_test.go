@@ -0,0 +1,74 @@
+package e2e
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiffShowsLocalChanges(t *testing.T) {
+	workDir := t.TempDir()
+	bin := buildFlexdot(t, workDir)
+
+	homeDir := filepath.Join(workDir, "home")
+	dotfilesDir := filepath.Join(workDir, "dotfiles")
+	writeFiles(t, workDir, map[string]string{
+		"dotfiles/index.yml":      "vim:\n  .vimrc: .\ngit:\n  .gitconfig: .\nbin:\n  logo.png: .\n  tool: bin\n",
+		"dotfiles/vim/.vimrc":     "set nu\n",
+		"dotfiles/git/.gitconfig": "[user]\n",
+		"dotfiles/bin/logo.png":   "\x89PNG\x00\x01",
+		"dotfiles/bin/tool":       "#!/bin/sh\n",
+		"home/.vimrc":             "set nu\nset list\n",
+		"home/.gitconfig":         "[user]\n",
+		"home/logo.png":           "\x89PNG\x00\x02",
+	})
+
+	cmd := flexdotCommand(bin, "diff", "-H", homeDir, "index.yml")
+	cmd.Dir = dotfilesDir
+	out, err := cmd.CombinedOutput()
+	if code := cmd.ProcessState.ExitCode(); code != 1 {
+		t.Fatalf("flexdot diff should exit with 1 when files differ, got %d (%v): %s", code, err, string(out))
+	}
+	for _, want := range []string{
+		"--- vim/.vimrc\n+++ .vimrc\n@@ -1 +1,2 @@\n set nu\n+set list\n",
+		"Binary files bin/logo.png and logo.png differ\n",
+		"2 files differ",
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("expected %q in the output, got: %s", want, string(out))
+		}
+	}
+	if strings.Contains(string(out), ".gitconfig") || strings.Contains(string(out), "tool") {
+		t.Errorf("identical and missing home files should not be shown, got: %s", string(out))
+	}
+
+	// Nothing is changed, and no differences means success
+	if fi, err := os.Lstat(filepath.Join(homeDir, ".vimrc")); err != nil || !fi.Mode().IsRegular() {
+		t.Fatalf("diff should not touch the home directory: %v", err)
+	}
+	if err := os.Remove(filepath.Join(homeDir, "logo.png")); err != nil {
+		t.Fatal(err)
+	}
+	cmd = flexdotCommand(bin, "diff", "-H", homeDir, "index.yml")
+	cmd.Dir = dotfilesDir
+	if out, _ := cmd.CombinedOutput(); !strings.Contains(string(out), "Diff: 1 file differs\n") {
+		t.Errorf("expected a singular summary, got: %s", string(out))
+	}
+	if err := os.Remove(filepath.Join(homeDir, ".vimrc")); err != nil {
+		t.Fatal(err)
+	}
+	cmd = flexdotCommand(bin, "diff", "-H", homeDir, "index.yml")
+	cmd.Dir = dotfilesDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("flexdot diff without differences failed: %v\nOutput: %s", err, string(out))
+	}
+
+	// Files that cannot be compared are trouble, not differences
+	cmd = flexdotCommand(bin, "diff", "-H", homeDir, "missing.yml")
+	cmd.Dir = dotfilesDir
+	out, err = cmd.CombinedOutput()
+	if code := cmd.ProcessState.ExitCode(); code != 2 {
+		t.Errorf("flexdot diff should exit with 2 on errors, got %d (%v): %s", code, err, string(out))
+	}
+}
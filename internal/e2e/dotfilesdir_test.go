@@ -0,0 +1,109 @@
+package e2e
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// prepareDotfilesDir creates a dotfiles dir with myfile.txt, an index.yml
+// linking it and a config.yml pointing at homeDir.
+func prepareDotfilesDir(t *testing.T, workDir, homeDir string) string {
+	t.Helper()
+	dotfilesDir := filepath.Join(workDir, "dotfiles")
+	if err := os.MkdirAll(filepath.Join(dotfilesDir, "sub", "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dotfilesDir, "myfile.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dotfilesDir, "index.yml"), []byte(`myfile.txt: .`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configYml := "home_dir: " + homeDir + "\nindex_yml: index.yml\n"
+	if err := os.WriteFile(filepath.Join(dotfilesDir, "config.yml"), []byte(configYml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(homeDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return dotfilesDir
+}
+
+func TestDotfilesDirFlagAndEnv(t *testing.T) {
+	workDir := t.TempDir()
+	bin := buildFlexdot(t, workDir)
+	homeDir := filepath.Join(workDir, "home")
+	dotfilesDir := prepareDotfilesDir(t, workDir, homeDir)
+
+	tests := []struct {
+		name string
+		args []string
+		env  []string
+	}{
+		{"-C", []string{"-C", dotfilesDir, "install"}, nil},
+		{"--dotfiles-dir", []string{"--dotfiles-dir=" + dotfilesDir, "install"}, nil},
+		{"FLEXDOT_DIR", []string{"install"}, []string{"FLEXDOT_DIR=" + dotfilesDir}},
+	}
+	for _, tt := range tests {
+		os.Remove(filepath.Join(homeDir, "myfile.txt"))
+
+		cmd := flexdotCommand(bin, tt.args...)
+		cmd.Dir = workDir
+		cmd.Env = append(cmd.Env, tt.env...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s: flexdot install failed: %v\n%s", tt.name, err, string(out))
+		}
+		if _, err := os.Readlink(filepath.Join(homeDir, "myfile.txt")); err != nil {
+			t.Errorf("%s: expected myfile.txt to be linked: %v", tt.name, err)
+		}
+	}
+
+	// init writes config.yml into the given directory
+	otherDir := filepath.Join(workDir, "other")
+	if err := os.MkdirAll(otherDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	cmd := flexdotCommand(bin, "-C", otherDir, "init")
+	cmd.Dir = workDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("flexdot init failed: %v\n%s", err, string(out))
+	}
+	if _, err := os.Stat(filepath.Join(otherDir, "config.yml")); err != nil {
+		t.Errorf("expected config.yml in %s: %v", otherDir, err)
+	}
+}
+
+func TestDotfilesDirDiscovery(t *testing.T) {
+	workDir := t.TempDir()
+	bin := buildFlexdot(t, workDir)
+	homeDir := filepath.Join(workDir, "home")
+	dotfilesDir := prepareDotfilesDir(t, workDir, homeDir)
+
+	// Run from a subdirectory of the repo
+	cmd := flexdotCommand(bin, "install")
+	cmd.Dir = filepath.Join(dotfilesDir, "sub", "dir")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("flexdot install failed: %v\n%s", err, string(out))
+	}
+	if _, err := os.Readlink(filepath.Join(homeDir, "myfile.txt")); err != nil {
+		t.Errorf("expected myfile.txt to be linked: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dotfilesDir, "sub", "dir", "backup")); err == nil {
+		t.Errorf("backup dir should not be created in the current directory")
+	}
+
+	// An index file argument is relative to the current directory, as
+	// produced by shell completion
+	os.Remove(filepath.Join(homeDir, "myfile.txt"))
+	cmd = flexdotCommand(bin, "install", filepath.Join("..", "..", "index.yml"))
+	cmd.Dir = filepath.Join(dotfilesDir, "sub", "dir")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("flexdot install ../../index.yml failed: %v\n%s", err, string(out))
+	}
+	if _, err := os.Readlink(filepath.Join(homeDir, "myfile.txt")); err != nil {
+		t.Errorf("expected myfile.txt to be linked: %v", err)
+	}
+}
@@ -2,7 +2,6 @@ package e2e
 
 import (
 	"os"
-	"os/exec"
 	"path/filepath"
 	"testing"
 )
@@ -11,7 +10,7 @@ func TestInitCreatesConfigYml(t *testing.T) {
 	workDir := t.TempDir()
 	bin := buildFlexdot(t, workDir)
 
-	cmd := exec.Command(bin, "init")
+	cmd := flexdotCommand(bin, "init")
 	cmd.Dir = workDir
 	out, err := cmd.CombinedOutput()
 	if err != nil {
@@ -46,7 +45,7 @@ func TestInitFailsIfConfigExists(t *testing.T) {
 		t.Fatalf("failed to create dummy config.yml: %v", err)
 	}
 
-	cmd := exec.Command(bin, "init")
+	cmd := flexdotCommand(bin, "init")
 	cmd.Dir = workDir
 	out, err := cmd.CombinedOutput()
 	if err == nil {
@@ -57,6 +56,81 @@ func TestInitFailsIfConfigExists(t *testing.T) {
 	}
 }
 
+func TestInitWithFlags(t *testing.T) {
+	workDir := t.TempDir()
+	bin := buildFlexdot(t, workDir)
+
+	cmd := flexdotCommand(bin, "init", "--home-dir", "/home/me", "--index", "mac.yml", "--keep-max-count", "3")
+	cmd.Dir = workDir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("flexdot init failed: %v\nOutput: %s", err, string(out))
+	}
+
+	data, err := os.ReadFile(filepath.Join(workDir, "config.yml"))
+	if err != nil {
+		t.Fatalf("config.yml was not created: %v", err)
+	}
+	content := string(data)
+	for _, want := range []string{"keep_max_count: 3", "home_dir: /home/me", "index_yml: mac.yml"} {
+		if !containsYAMLKey(content, want) {
+			t.Errorf("config.yml missing %q, got:\n%s", want, content)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "mac.yml")); !os.IsNotExist(err) {
+		t.Errorf("index file should only be created with --scaffold")
+	}
+
+	cmd = flexdotCommand(bin, "init", "--keep-max-count", "-2")
+	cmd.Dir = t.TempDir()
+	if out, err := cmd.CombinedOutput(); err == nil {
+		t.Errorf("flexdot init should reject a negative --keep-max-count, got: %s", string(out))
+	}
+}
+
+func TestInitScaffold(t *testing.T) {
+	workDir := t.TempDir()
+	bin := buildFlexdot(t, workDir)
+
+	// An existing .gitignore is extended, not replaced
+	if err := os.WriteFile(filepath.Join(workDir, ".gitignore"), []byte("backup/\n*.swp"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := flexdotCommand(bin, "init", "--scaffold", "--index", "work.yml", "--yes")
+	cmd.Dir = workDir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("flexdot init --scaffold failed: %v\nOutput: %s", err, string(out))
+	}
+
+	data, err := os.ReadFile(filepath.Join(workDir, "config.yml"))
+	if err != nil {
+		t.Fatalf("config.yml was not created: %v", err)
+	}
+	if !containsYAMLKey(string(data), "index_yml: work.yml") {
+		t.Errorf("config.yml missing index_yml: work.yml, got:\n%s", string(data))
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "work.yml")); err != nil {
+		t.Errorf("starter index file was not created: %v", err)
+	}
+	gitignore, err := os.ReadFile(filepath.Join(workDir, ".gitignore"))
+	if err != nil {
+		t.Fatalf(".gitignore was not created: %v", err)
+	}
+	if got, want := string(gitignore), "backup/\n*.swp\nconfig.local.yml\n"; got != want {
+		t.Errorf(".gitignore = %q, want %q", got, want)
+	}
+
+	// The starter index installs nothing
+	homeDir := t.TempDir()
+	cmd = flexdotCommand(bin, "install", "-H", homeDir)
+	cmd.Dir = workDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("install with the starter index failed: %v\nOutput: %s", err, string(out))
+	}
+}
+
 // containsYAMLKey checks if the YAML content contains the given key (and value).
 func containsYAMLKey(content, key string) bool {
 	return containsLine(content, key)
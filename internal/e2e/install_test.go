@@ -21,6 +21,36 @@ func buildFlexdot(t *testing.T, workDir string) string {
 	return bin
 }
 
+// writeFiles writes files under dir, creating their parent directories. The
+// keys of files are slash-separated paths relative to dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// flexdotCommand returns the command running bin with args, away from the
+// developer's environment: the FLEXDOT_* variables are removed and
+// XDG_CONFIG_HOME points into the directory of bin, so that no user config
+// file is read.
+func flexdotCommand(bin string, args ...string) *exec.Cmd {
+	cmd := exec.Command(bin, args...)
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "FLEXDOT_") && !strings.HasPrefix(kv, "XDG_CONFIG_HOME=") {
+			cmd.Env = append(cmd.Env, kv)
+		}
+	}
+	cmd.Env = append(cmd.Env, "XDG_CONFIG_HOME="+filepath.Join(filepath.Dir(bin), "xdg"))
+	return cmd
+}
+
 func TestInstallSymlinkBasic(t *testing.T) {
 	workDir := t.TempDir()
 	bin := buildFlexdot(t, workDir)
@@ -49,7 +79,7 @@ func TestInstallSymlinkBasic(t *testing.T) {
 	}
 
 	// Run flexdot install
-	cmd := exec.Command(bin, "install", "-H", homeDir, "index.yml")
+	cmd := flexdotCommand(bin, "install", "-H", homeDir, "index.yml")
 	cmd.Dir = dotfilesDir
 	out, err := cmd.CombinedOutput()
 	if err != nil {
@@ -103,14 +133,14 @@ func TestInstallAlreadyLinked(t *testing.T) {
 	}
 
 	// 1st install
-	cmd := exec.Command(bin, "install", "-H", homeDir, "index.yml")
+	cmd := flexdotCommand(bin, "install", "-H", homeDir, "index.yml")
 	cmd.Dir = dotfilesDir
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		t.Fatalf("flexdot install (1st) failed: %v\n%s", err, string(out))
 	}
 	// 2nd install (should print "already linked:" and not change the symlink)
-	cmd2 := exec.Command(bin, "install", "-H", homeDir, "index.yml")
+	cmd2 := flexdotCommand(bin, "install", "-H", homeDir, "index.yml")
 	cmd2.Dir = dotfilesDir
 	out2, err := cmd2.CombinedOutput()
 	if err != nil {
@@ -166,7 +196,7 @@ func TestInstallLinkUpdated(t *testing.T) {
 	}
 
 	// 1st install (link to v1)
-	cmd := exec.Command(bin, "install", "-H", homeDir, "index.yml")
+	cmd := flexdotCommand(bin, "install", "-H", homeDir, "index.yml")
 	cmd.Dir = dotfilesDir
 	out, err := cmd.CombinedOutput()
 	if err != nil {
@@ -202,7 +232,7 @@ func TestInstallLinkUpdated(t *testing.T) {
 	}
 
 	// 2nd install (should update the link target, output "link updated:")
-	cmd2 := exec.Command(bin, "install", "-H", homeDir, "index.yml")
+	cmd2 := flexdotCommand(bin, "install", "-H", homeDir, "index.yml")
 	cmd2.Dir = dotfilesDir
 	out2, err := cmd2.CombinedOutput()
 	if err != nil {
@@ -261,7 +291,7 @@ func TestBackupAndClearBackups(t *testing.T) {
 	}
 
 	// 1st install (should backup the existing file and create a symlink)
-	cmd := exec.Command(bin, "install", "-H", homeDir, "index.yml")
+	cmd := flexdotCommand(bin, "install", "-H", homeDir, "index.yml")
 	cmd.Dir = dotfilesDir
 	out, err := cmd.CombinedOutput()
 	if err != nil {
@@ -295,7 +325,7 @@ func TestBackupAndClearBackups(t *testing.T) {
 	}
 
 	// Run clear-backups
-	cmd2 := exec.Command(bin, "clear-backups")
+	cmd2 := flexdotCommand(bin, "clear-backups", "--yes")
 	cmd2.Dir = dotfilesDir
 	out2, err := cmd2.CombinedOutput()
 	if err != nil {
@@ -344,7 +374,7 @@ index_yml: index.yml
 	}
 
 	// Run flexdot install (no args, config.yml used)
-	cmd := exec.Command(bin, "install")
+	cmd := flexdotCommand(bin, "install")
 	cmd.Dir = dotfilesDir
 	out, err := cmd.CombinedOutput()
 	if err != nil {
@@ -381,7 +411,7 @@ func TestInstallMissingArgsAndConfig(t *testing.T) {
 	}
 
 	// Run flexdot install (should fail fast)
-	cmd := exec.Command(bin, "install")
+	cmd := flexdotCommand(bin, "install")
 	cmd.Dir = dotfilesDir
 	out, err := cmd.CombinedOutput()
 	if err == nil {
@@ -436,7 +466,7 @@ func TestInstallWildcard(t *testing.T) {
 	}
 
 	// Run flexdot install
-	cmd := exec.Command(bin, "install", "-H", homeDir, "index.yml")
+	cmd := flexdotCommand(bin, "install", "-H", homeDir, "index.yml")
 	cmd.Dir = dotfilesDir
 	out, err := cmd.CombinedOutput()
 	if err != nil {
@@ -509,12 +539,15 @@ func TestInstallWildcardNoMatches(t *testing.T) {
 	}
 
 	// Run flexdot install (should succeed even with no matches)
-	cmd := exec.Command(bin, "install", "-H", homeDir, "index.yml")
+	cmd := flexdotCommand(bin, "install", "-H", homeDir, "index.yml")
 	cmd.Dir = dotfilesDir
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		t.Fatalf("flexdot install with wildcard (no matches) failed: %v\n%s", err, string(out))
 	}
+	if !strings.Contains(string(out), "warning:") || !strings.Contains(string(out), "macOS/codex/prompts/*.md") {
+		t.Errorf("expected a warning for the pattern, got:\n%s", out)
+	}
 
 	// Check that target directory is not created since there were no matches
 	targetDir := filepath.Join(homeDir, ".codex", "prompts")
@@ -523,5 +556,287 @@ func TestInstallWildcardNoMatches(t *testing.T) {
 	if err == nil && len(entries) > 0 {
 		t.Errorf("expected no symlinks to be created, but found %d entries", len(entries))
 	}
+
+	// --strict turns the warning into a failure
+	cmd = flexdotCommand(bin, "install", "-H", homeDir, "--strict", "index.yml")
+	cmd.Dir = dotfilesDir
+	if out, err := cmd.CombinedOutput(); err == nil {
+		t.Errorf("expected flexdot install --strict to fail, got:\n%s", out)
+	}
+
+	// so does strict: true in config.yml
+	if err := os.WriteFile(filepath.Join(dotfilesDir, "config.yml"), []byte("strict: true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd = flexdotCommand(bin, "install", "-H", homeDir, "index.yml")
+	cmd.Dir = dotfilesDir
+	if out, err := cmd.CombinedOutput(); err == nil {
+		t.Errorf("expected flexdot install with strict config to fail, got:\n%s", out)
+	}
 }
 
+func TestInstallReportsEntryErrors(t *testing.T) {
+	workDir := t.TempDir()
+	bin := buildFlexdot(t, workDir)
+
+	// Prepare dotfiles dir and file
+	dotfilesDir := filepath.Join(workDir, "dotfiles")
+	if err := os.MkdirAll(dotfilesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	dotfile := filepath.Join(dotfilesDir, "myfile.txt")
+	if err := os.WriteFile(dotfile, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Prepare index.yml whose destination directory is a regular file
+	indexYml := filepath.Join(dotfilesDir, "index.yml")
+	indexContent := `myfile.txt: blocker`
+	if err := os.WriteFile(indexYml, []byte(indexContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Prepare home dir with the blocking file
+	homeDir := filepath.Join(workDir, "home")
+	if err := os.MkdirAll(homeDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(homeDir, "blocker"), []byte("not a dir"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Run flexdot install (should fail and describe the failing entry)
+	cmd := flexdotCommand(bin, "install", "-H", homeDir, "index.yml")
+	cmd.Dir = dotfilesDir
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected install to fail, but it succeeded:\n%s", string(out))
+	}
+	if want := "Error: lstat myfile.txt:"; !strings.Contains(string(out), want) {
+		t.Errorf("expected output to contain %q, got: %s", want, string(out))
+	}
+	if want := "encountered 1 error during install"; !strings.Contains(string(out), want) {
+		t.Errorf("expected output to contain %q, got: %s", want, string(out))
+	}
+}
+
+func TestInstallBackupDirFromConfigYml(t *testing.T) {
+	workDir := t.TempDir()
+	bin := buildFlexdot(t, workDir)
+
+	// Prepare dotfiles dir and file
+	dotfilesDir := filepath.Join(workDir, "dotfiles")
+	if err := os.MkdirAll(dotfilesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	dotfile := filepath.Join(dotfilesDir, "myfile.txt")
+	if err := os.WriteFile(dotfile, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Prepare index.yml and config.yml with a backup_dir outside the dotfiles dir
+	indexYml := filepath.Join(dotfilesDir, "index.yml")
+	if err := os.WriteFile(indexYml, []byte(`myfile.txt: .`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configYml := filepath.Join(dotfilesDir, "config.yml")
+	if err := os.WriteFile(configYml, []byte("backup_dir: ../backups\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Prepare home dir and create a file that will be backed up
+	homeDir := filepath.Join(workDir, "home")
+	if err := os.MkdirAll(homeDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(homeDir, "myfile.txt"), []byte("old content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := flexdotCommand(bin, "install", "-H", homeDir, "index.yml")
+	cmd.Dir = dotfilesDir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("flexdot install failed: %v\n%s", err, string(out))
+	}
+
+	backupDir := filepath.Join(workDir, "backups")
+	entries, err := os.ReadDir(backupDir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected one snapshot in %s: %v", backupDir, err)
+	}
+	if _, err := os.Stat(filepath.Join(dotfilesDir, "backup")); err == nil {
+		t.Errorf("backup dir should not be created in the dotfiles dir")
+	}
+}
+
+func TestInstallExpandsTildeAndEnv(t *testing.T) {
+	workDir := t.TempDir()
+	bin := buildFlexdot(t, workDir)
+
+	// Prepare dotfiles dir and files
+	dotfilesDir := filepath.Join(workDir, "dotfiles")
+	if err := os.MkdirAll(dotfilesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"init.lua", "gitconfig"} {
+		if err := os.WriteFile(filepath.Join(dotfilesDir, name), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Prepare index.yml with env destinations and config.yml with ~ in home_dir
+	indexContent := `init.lua: $XDG_CONFIG_HOME/nvim
+gitconfig: ${GIT_CONFIG_DIR:-~/.config/git}
+`
+	if err := os.WriteFile(filepath.Join(dotfilesDir, "index.yml"), []byte(indexContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dotfilesDir, "config.yml"), []byte("home_dir: ~/\nindex_yml: $INDEX_NAME.yml\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	homeDir := filepath.Join(workDir, "home")
+	if err := os.MkdirAll(homeDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := flexdotCommand(bin, "install")
+	cmd.Dir = dotfilesDir
+	cmd.Env = append(cmd.Env, "HOME="+homeDir, "XDG_CONFIG_HOME="+filepath.Join(homeDir, ".config"), "INDEX_NAME=index")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("flexdot install failed: %v\n%s", err, string(out))
+	}
+	for _, link := range []string{".config/nvim/init.lua", ".config/git/gitconfig"} {
+		if _, err := os.Readlink(filepath.Join(homeDir, link)); err != nil {
+			t.Errorf("expected %s to be a symlink: %v", link, err)
+		}
+	}
+
+	// An undefined variable without a default is an error
+	cmd = flexdotCommand(bin, "install")
+	cmd.Dir = dotfilesDir
+	cmd.Env = append(withoutEnv(cmd.Env, "XDG_CONFIG_HOME"), "HOME="+homeDir, "INDEX_NAME=index")
+	out, err = cmd.CombinedOutput()
+	if err == nil || !strings.Contains(string(out), "undefined variable: $XDG_CONFIG_HOME") {
+		t.Errorf("expected an undefined variable error, got %v:\n%s", err, out)
+	}
+}
+
+// withoutEnv removes the variable name from env.
+func withoutEnv(env []string, name string) []string {
+	var result []string
+	for _, kv := range env {
+		if !strings.HasPrefix(kv, name+"=") {
+			result = append(result, kv)
+		}
+	}
+	return result
+}
+
+func TestInstallDefaultsHomeDirToUserHome(t *testing.T) {
+	workDir := t.TempDir()
+	bin := buildFlexdot(t, workDir)
+
+	// Prepare dotfiles dir, file and index.yml
+	dotfilesDir := filepath.Join(workDir, "dotfiles")
+	if err := os.MkdirAll(dotfilesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dotfilesDir, "myfile.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dotfilesDir, "index.yml"), []byte(`myfile.txt: .`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	homeDir := filepath.Join(workDir, "home")
+	if err := os.MkdirAll(homeDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without -H or config.yml, $HOME is used
+	cmd := flexdotCommand(bin, "install", "--verbose", "index.yml")
+	cmd.Dir = dotfilesDir
+	cmd.Env = append(cmd.Env, "HOME="+homeDir)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("flexdot install failed: %v\n%s", err, string(out))
+	}
+	if want := "home_dir: " + homeDir + " (from the current user's home directory)"; !strings.Contains(string(out), want) {
+		t.Errorf("expected output to contain %q, got:\n%s", want, out)
+	}
+	if _, err := os.Readlink(filepath.Join(homeDir, "myfile.txt")); err != nil {
+		t.Errorf("expected myfile.txt to be linked in $HOME: %v", err)
+	}
+
+	// An explicit --home_dir still wins
+	otherHome := filepath.Join(workDir, "other")
+	if err := os.MkdirAll(otherHome, 0755); err != nil {
+		t.Fatal(err)
+	}
+	cmd = flexdotCommand(bin, "install", "--verbose", "--home_dir", otherHome, "index.yml")
+	cmd.Dir = dotfilesDir
+	cmd.Env = append(cmd.Env, "HOME="+homeDir)
+	out, err = cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("flexdot install failed: %v\n%s", err, string(out))
+	}
+	if want := "(from --home_dir)"; !strings.Contains(string(out), want) {
+		t.Errorf("expected output to contain %q, got:\n%s", want, out)
+	}
+	if _, err := os.Readlink(filepath.Join(otherHome, "myfile.txt")); err != nil {
+		t.Errorf("expected myfile.txt to be linked in --home_dir: %v", err)
+	}
+}
+
+func TestInstallAdoptAndInteractive(t *testing.T) {
+	workDir := t.TempDir()
+	bin := buildFlexdot(t, workDir)
+
+	homeDir := filepath.Join(workDir, "home")
+	dotfilesDir := filepath.Join(workDir, "dotfiles")
+	writeFiles(t, workDir, map[string]string{
+		"dotfiles/index.yml":  "vim:\n  .vimrc: .\n",
+		"dotfiles/vim/.vimrc": "set nu\n",
+		"home/.vimrc":         "set nu\nset list\n",
+	})
+	dotfile := filepath.Join(dotfilesDir, "vim", ".vimrc")
+	homeFile := filepath.Join(homeDir, ".vimrc")
+
+	// Skipping leaves the home file alone
+	cmd := flexdotCommand(bin, "install", "--interactive", "-H", homeDir, "index.yml")
+	cmd.Dir = dotfilesDir
+	cmd.Stdin = strings.NewReader("s\n")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("flexdot install --interactive failed: %v\nOutput: %s", err, string(out))
+	}
+	if !strings.Contains(string(out), "+set list\n") || !strings.Contains(string(out), "skipped:") {
+		t.Errorf("expected a diff and a skipped entry, got: %s", string(out))
+	}
+	if fi, err := os.Lstat(homeFile); err != nil || !fi.Mode().IsRegular() {
+		t.Fatalf("skipped home file should be left in place: %v", err)
+	}
+
+	// --adopt moves the home file into the repo
+	cmd = flexdotCommand(bin, "install", "--adopt", "-H", homeDir, "index.yml")
+	cmd.Dir = dotfilesDir
+	out, err = cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("flexdot install --adopt failed: %v\nOutput: %s", err, string(out))
+	}
+	if !strings.Contains(string(out), "link created:\033[0m .vimrc (adopted)") {
+		t.Errorf("expected the adopted log, got: %s", string(out))
+	}
+	if dest, err := os.Readlink(homeFile); err != nil || dest != dotfile {
+		t.Errorf("expected %s to link to %s, got %q (%v)", homeFile, dotfile, dest, err)
+	}
+	if data, _ := os.ReadFile(dotfile); string(data) != "set nu\nset list\n" {
+		t.Errorf("dotfile content is %q, want the home file's", data)
+	}
+	if _, err := os.Stat(filepath.Join(dotfilesDir, "backup")); err == nil {
+		t.Error("--adopt should not create a backup")
+	}
+}
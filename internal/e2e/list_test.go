@@ -0,0 +1,90 @@
+package e2e
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestListAndWhich(t *testing.T) {
+	workDir := t.TempDir()
+	bin := buildFlexdot(t, workDir)
+
+	homeDir := filepath.Join(workDir, "home")
+	dotfilesDir := filepath.Join(workDir, "dotfiles")
+	writeFiles(t, workDir, map[string]string{
+		"dotfiles/index.yml":             "vim:\n  .vimrc: .\ncodex:\n  prompts/*.md: .codex/prompts\nnvim: .config\n",
+		"dotfiles/config.yml":            "index_yml: index.yml\nhome_dir: " + homeDir + "\n",
+		"dotfiles/vim/.vimrc":            "set nu",
+		"dotfiles/codex/prompts/code.md": "code",
+		"dotfiles/codex/prompts/test.md": "test",
+		"dotfiles/nvim/init.lua":         "-- init",
+	})
+	run := func(args ...string) (string, error) {
+		t.Helper()
+		cmd := flexdotCommand(bin, args...)
+		cmd.Dir = dotfilesDir
+		out, err := cmd.CombinedOutput()
+		return string(out), err
+	}
+
+	// list prints the expanded entries sorted by source
+	out, err := run("list")
+	if err != nil {
+		t.Fatalf("flexdot list failed: %v\nOutput: %s", err, out)
+	}
+	want := strings.Join([]string{
+		filepath.Join("codex", "prompts", "code.md") + " -> " + filepath.Join(".codex", "prompts", "code.md") + " (index.yml)",
+		filepath.Join("codex", "prompts", "test.md") + " -> " + filepath.Join(".codex", "prompts", "test.md") + " (index.yml)",
+		"nvim -> " + filepath.Join(".config", "nvim") + " (index.yml)",
+		filepath.Join("vim", ".vimrc") + " -> .vimrc (index.yml)",
+	}, "\n") + "\n"
+	if out != want {
+		t.Errorf("flexdot list =\n%s\nwant:\n%s", out, want)
+	}
+
+	out, err = run("list", "--json")
+	if err != nil {
+		t.Fatalf("flexdot list --json failed: %v\nOutput: %s", err, out)
+	}
+	var items []map[string]string
+	if err := json.Unmarshal([]byte(out), &items); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out)
+	}
+	if len(items) != 4 {
+		t.Fatalf("got %d items, want 4: %s", len(items), out)
+	}
+	first := map[string]string{
+		"source":      filepath.Join(dotfilesDir, "codex", "prompts", "code.md"),
+		"destination": filepath.Join(homeDir, ".codex", "prompts", "code.md"),
+		"index":       filepath.Join(dotfilesDir, "index.yml"),
+		"key":         "codex/prompts/*.md",
+	}
+	for key, value := range first {
+		if items[0][key] != value {
+			t.Errorf("items[0][%q] = %q, want %q", key, items[0][key], value)
+		}
+	}
+
+	// which finds the entry of a link, even before install
+	out, err = run("which", filepath.Join(homeDir, ".codex", "prompts", "code.md"))
+	if err != nil {
+		t.Fatalf("flexdot which failed: %v\nOutput: %s", err, out)
+	}
+	want = "source: " + filepath.Join("codex", "prompts", "code.md") + "\nentry: codex/prompts/*.md\nindex: index.yml\n"
+	if out != want {
+		t.Errorf("flexdot which =\n%s\nwant:\n%s", out, want)
+	}
+
+	// and of a file inside a linked directory
+	out, err = run("which", filepath.Join(homeDir, ".config", "nvim", "init.lua"))
+	if err != nil || !strings.Contains(out, "source: "+filepath.Join("nvim", "init.lua")+"\nentry: nvim\n") {
+		t.Errorf("flexdot which in a linked directory: %v\nOutput: %s", err, out)
+	}
+
+	out, err = run("which", filepath.Join(homeDir, ".bashrc"))
+	if err == nil || !strings.Contains(out, "not managed") {
+		t.Errorf("expected which to fail for an unmanaged file, got: %v %s", err, out)
+	}
+}
@@ -0,0 +1,90 @@
+package e2e
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInstallWithProfiles(t *testing.T) {
+	workDir := t.TempDir()
+	bin := buildFlexdot(t, workDir)
+
+	// Prepare dotfiles dir with an index file per profile
+	dotfilesDir := filepath.Join(workDir, "dotfiles")
+	writeFiles(t, dotfilesDir, map[string]string{
+		"gitconfig":    "[user]",
+		"work.yml":     "gitconfig: $GIT_DIR_NAME\n",
+		"personal.yml": "gitconfig: .\n",
+	})
+
+	workHome := filepath.Join(workDir, "work")
+	personalHome := filepath.Join(workDir, "personal")
+	configYml := `index_yml: personal.yml
+keep_max_count: 10
+vars:
+  GIT_DIR_NAME: .config/git
+profiles:
+  work:
+    index_yml: work.yml
+    home_dir: ` + workHome + `
+    keep_max_count: 3
+    vars:
+      GIT_DIR_NAME: .config/git-work
+  personal:
+    home_dir: ` + personalHome + `
+`
+	if err := os.WriteFile(filepath.Join(dotfilesDir, "config.yml"), []byte(configYml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(env []string, args ...string) (string, error) {
+		t.Helper()
+		cmd := flexdotCommand(bin, args...)
+		cmd.Dir = dotfilesDir
+		cmd.Env = append(cmd.Env, env...)
+		out, err := cmd.CombinedOutput()
+		return string(out), err
+	}
+
+	// --profile selects the index, home dir and vars of the profile
+	if out, err := run(nil, "install", "--profile", "work"); err != nil {
+		t.Fatalf("flexdot install --profile work failed: %v\n%s", err, out)
+	}
+	if _, err := os.Readlink(filepath.Join(workHome, ".config", "git-work", "gitconfig")); err != nil {
+		t.Errorf("expected gitconfig to be linked into the work home: %v", err)
+	}
+
+	// FLEXDOT_PROFILE does the same
+	if out, err := run([]string{"FLEXDOT_PROFILE=personal"}, "install"); err != nil {
+		t.Fatalf("flexdot install with FLEXDOT_PROFILE failed: %v\n%s", err, out)
+	}
+	if _, err := os.Readlink(filepath.Join(personalHome, "gitconfig")); err != nil {
+		t.Errorf("expected gitconfig to be linked into the personal home: %v", err)
+	}
+
+	// config.local.yml can pin the profile of the machine
+	if err := os.WriteFile(filepath.Join(dotfilesDir, "config.local.yml"), []byte("profile: work\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := run(nil, "config", "show", "--origin")
+	if err != nil {
+		t.Fatalf("flexdot config show failed: %v\n%s", err, out)
+	}
+	for _, want := range []string{
+		"(profile work)\tkeep_max_count: 3",
+		"(profile work)\tindex_yml: work.yml",
+		"config.local.yml\tprofile: work",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	// Unknown profiles are errors
+	out, err = run(nil, "install", "--profile", "home")
+	if err == nil || !strings.Contains(out, `unknown profile "home", expected one of: personal, work`) {
+		t.Errorf("expected an unknown profile error, got %v:\n%s", err, out)
+	}
+}
@@ -0,0 +1,110 @@
+package e2e
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInstallBacksUpForeignSymlinkAndRestore(t *testing.T) {
+	workDir := t.TempDir()
+	bin := buildFlexdot(t, workDir)
+
+	// Prepare dotfiles dir and file
+	dotfilesDir := filepath.Join(workDir, "dotfiles")
+	if err := os.MkdirAll(filepath.Join(dotfilesDir, "bash"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dotfilesDir, "bash", ".bashrc"), []byte("export A=1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	indexYml := filepath.Join(dotfilesDir, "index.yml")
+	if err := os.WriteFile(indexYml, []byte("bash:\n  .bashrc: .\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Prepare home dir with a hand-made symlink pointing outside the dotfiles dir
+	homeDir := filepath.Join(workDir, "home")
+	if err := os.MkdirAll(homeDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	corpBashrc := filepath.Join(workDir, "opt", "corp", "bashrc")
+	linkPath := filepath.Join(homeDir, ".bashrc")
+	if err := os.Symlink(corpBashrc, linkPath); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := flexdotCommand(bin, "install", "-H", homeDir, "index.yml")
+	cmd.Dir = dotfilesDir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("flexdot install failed: %v\n%s", err, string(out))
+	}
+	if !strings.Contains(string(out), "link updated:") || !strings.Contains(string(out), "(backup)") {
+		t.Errorf("expected output to contain 'link updated:' with '(backup)', got: %s", string(out))
+	}
+	if dest, _ := os.Readlink(linkPath); dest != filepath.Join(dotfilesDir, "bash", ".bashrc") {
+		t.Errorf("symlink points to %s after install", dest)
+	}
+
+	// Restore the newest snapshot
+	cmd2 := flexdotCommand(bin, "restore", "-H", homeDir)
+	cmd2.Dir = dotfilesDir
+	out2, err := cmd2.CombinedOutput()
+	if err != nil {
+		t.Fatalf("flexdot restore failed: %v\n%s", err, string(out2))
+	}
+	if !strings.Contains(string(out2), "restored:") || !strings.Contains(string(out2), ".bashrc") {
+		t.Errorf("expected output to report .bashrc as restored, got: %s", string(out2))
+	}
+	if dest, _ := os.Readlink(linkPath); dest != corpBashrc {
+		t.Errorf("restored symlink points to %s, want %s", dest, corpBashrc)
+	}
+}
+
+func TestRestoreOutsideHomeToOriginalPaths(t *testing.T) {
+	workDir := t.TempDir()
+	bin := buildFlexdot(t, workDir)
+
+	dotfilesDir := filepath.Join(workDir, "dotfiles")
+	homeDir := filepath.Join(workDir, "home")
+	fooConfig := filepath.Join(workDir, "etc", "foo", "config")
+	barConfig := filepath.Join(workDir, "opt", "bar", "config")
+	writeFiles(t, workDir, map[string]string{
+		"dotfiles/foo/config": "foo",
+		"dotfiles/bar/config": "bar",
+		"dotfiles/index.yml":  "foo:\n  config: " + filepath.Dir(fooConfig) + "\nbar:\n  config: " + filepath.Dir(barConfig) + "\n",
+		"dotfiles/config.yml": "allow_outside_home: true\n",
+		"etc/foo/config":      "old foo",
+		"opt/bar/config":      "old bar",
+	})
+	if err := os.MkdirAll(homeDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := flexdotCommand(bin, "install", "-H", homeDir, "index.yml")
+	cmd.Dir = dotfilesDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("flexdot install failed: %v\n%s", err, string(out))
+	}
+
+	// Both files come back where they were, not into the home directory
+	cmd = flexdotCommand(bin, "restore", "-H", homeDir)
+	cmd.Dir = dotfilesDir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("flexdot restore failed: %v\n%s", err, string(out))
+	}
+	for path, want := range map[string]string{fooConfig: "old foo", barConfig: "old bar"} {
+		if data, err := os.ReadFile(path); err != nil || string(data) != want {
+			t.Errorf("%s = %q (%v), want %q", path, data, err, want)
+		}
+		if !strings.Contains(string(out), "restored:\033[0m "+path) {
+			t.Errorf("expected %s to be reported as restored, got: %s", path, out)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(homeDir, "config")); err == nil {
+		t.Errorf("nothing should be restored into the home directory as config")
+	}
+}
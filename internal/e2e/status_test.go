@@ -0,0 +1,123 @@
+package e2e
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInstallMissingSource(t *testing.T) {
+	workDir := t.TempDir()
+	bin := buildFlexdot(t, workDir)
+
+	// Prepare dotfiles dir with an index entry whose source does not exist
+	dotfilesDir := filepath.Join(workDir, "dotfiles")
+	if err := os.MkdirAll(dotfilesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	indexYml := filepath.Join(dotfilesDir, "index.yml")
+	if err := os.WriteFile(indexYml, []byte(`myfiel.txt: .`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	homeDir := filepath.Join(workDir, "home")
+	if err := os.MkdirAll(homeDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := flexdotCommand(bin, "install", "-H", homeDir, "index.yml")
+	cmd.Dir = dotfilesDir
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected install to fail, but it succeeded:\n%s", string(out))
+	}
+	if !strings.Contains(string(out), "missing source:") {
+		t.Errorf("expected output to contain 'missing source:', got: %s", string(out))
+	}
+	if _, err := os.Lstat(filepath.Join(homeDir, "myfiel.txt")); err == nil {
+		t.Errorf("a dangling symlink should not be created")
+	}
+}
+
+func TestStatusDetectsBrokenLinks(t *testing.T) {
+	workDir := t.TempDir()
+	bin := buildFlexdot(t, workDir)
+
+	// Prepare dotfiles dir and files
+	dotfilesDir := filepath.Join(workDir, "dotfiles")
+	if err := os.MkdirAll(dotfilesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"kept.txt", "deleted.txt"} {
+		if err := os.WriteFile(filepath.Join(dotfilesDir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	indexYml := filepath.Join(dotfilesDir, "index.yml")
+	if err := os.WriteFile(indexYml, []byte("kept.txt: .\ndeleted.txt: .\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	homeDir := filepath.Join(workDir, "home")
+	if err := os.MkdirAll(homeDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := flexdotCommand(bin, "install", "-H", homeDir, "index.yml")
+	cmd.Dir = dotfilesDir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("flexdot install failed: %v\n%s", err, string(out))
+	}
+
+	// Everything is linked right after install
+	cmd2 := flexdotCommand(bin, "status", "-H", homeDir, "index.yml")
+	cmd2.Dir = dotfilesDir
+	out2, err := cmd2.CombinedOutput()
+	if err != nil {
+		t.Fatalf("flexdot status failed: %v\n%s", err, string(out2))
+	}
+	if strings.Count(string(out2), "linked:") != 2 {
+		t.Errorf("expected two linked entries, got: %s", string(out2))
+	}
+
+	// Deleting a source from the repo breaks its link
+	if err := os.Remove(filepath.Join(dotfilesDir, "deleted.txt")); err != nil {
+		t.Fatal(err)
+	}
+	cmd3 := flexdotCommand(bin, "status", "-H", homeDir, "index.yml")
+	cmd3.Dir = dotfilesDir
+	out3, err := cmd3.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected status to fail with a broken link:\n%s", string(out3))
+	}
+	if !strings.Contains(string(out3), "broken link:") || !strings.Contains(string(out3), "deleted.txt (source deleted)") {
+		t.Errorf("expected deleted.txt to be reported as a broken link, got: %s", string(out3))
+	}
+}
+
+func TestStatusReportsDirectoryInTheWay(t *testing.T) {
+	workDir := t.TempDir()
+	bin := buildFlexdot(t, workDir)
+
+	dotfilesDir := filepath.Join(workDir, "dotfiles")
+	homeDir := filepath.Join(workDir, "home")
+	for _, dir := range []string{filepath.Join(dotfilesDir, "nvim"), filepath.Join(homeDir, ".config", "nvim")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dotfilesDir, "index.yml"), []byte("nvim: .config\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, command := range []string{"status", "install"} {
+		cmd := flexdotCommand(bin, command, "-H", homeDir, "index.yml")
+		cmd.Dir = dotfilesDir
+		out, err := cmd.CombinedOutput()
+		if err == nil || !strings.Contains(string(out), "a directory or special file is in the way") {
+			t.Errorf("expected %s to report the directory in the way, got: %v\n%s", command, err, string(out))
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Package expand expands a leading tilde and environment variables in the
+// paths of index and config files.
+//
+// Supported forms:
+//
+//	~, ~/path        the home directory
+//	$VAR, ${VAR}     the value of VAR, an error when it is not set
+//	${VAR:-default}  the value of VAR, or default when it is unset or empty;
+//	                 default is expanded the same way
+//	$$               a literal '$'
+package expand
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUndefined is returned for a variable that is not set and has no default.
+var ErrUndefined = errors.New("undefined variable")
+
+// LookupFunc returns the value of an environment variable and whether it is
+// set, like os.LookupEnv.
+type LookupFunc func(name string) (string, bool)
+
+// WithVars returns a LookupFunc that looks up vars before the environment.
+func WithVars(vars map[string]string) LookupFunc {
+	return func(name string) (string, bool) {
+		if value, ok := vars[name]; ok {
+			return value, true
+		}
+		return os.LookupEnv(name)
+	}
+}
+
+// Path expands a leading ~ to home and then the environment variables of s,
+// looked up with os.LookupEnv.
+func Path(s, home string) (string, error) {
+	return PathFunc(s, home, os.LookupEnv)
+}
+
+// PathFunc is Path with a custom variable lookup.
+func PathFunc(s, home string, lookup LookupFunc) (string, error) {
+	if s == "~" {
+		s = home
+	} else if strings.HasPrefix(s, "~/") {
+		s = filepath.Join(home, s[2:])
+	}
+	return env(s, home, lookup)
+}
+
+// env expands the environment variables of s. home is used for the tilde in
+// the defaults of ${VAR:-default}.
+func env(s, home string, lookup LookupFunc) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		switch next := s[i+1]; {
+		case next == '$':
+			b.WriteByte('$')
+			i++
+		case next == '{':
+			end := closingBrace(s, i+1)
+			if end < 0 {
+				return "", fmt.Errorf("unclosed ${ in %q", s)
+			}
+			value, err := braced(s[i+2:end], home, lookup)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(value)
+			i = end
+		case isNameChar(next, true):
+			end := i + 2
+			for end < len(s) && isNameChar(s[end], false) {
+				end++
+			}
+			name := s[i+1 : end]
+			value, ok := lookup(name)
+			if !ok {
+				return "", fmt.Errorf("%w: $%s", ErrUndefined, name)
+			}
+			b.WriteString(value)
+			i = end - 1
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String(), nil
+}
+
+// braced expands the body of ${...}.
+func braced(body, home string, lookup LookupFunc) (string, error) {
+	name, def, hasDefault := strings.Cut(body, ":-")
+	if name == "" || !isName(name) {
+		return "", fmt.Errorf("invalid variable ${%s}", body)
+	}
+	value, ok := lookup(name)
+	if hasDefault {
+		if ok && value != "" {
+			return value, nil
+		}
+		return PathFunc(def, home, lookup)
+	}
+	if !ok {
+		return "", fmt.Errorf("%w: ${%s}", ErrUndefined, name)
+	}
+	return value, nil
+}
+
+// closingBrace returns the index of the '}' closing the '{' at start.
+func closingBrace(s string, start int) int {
+	depth := 0
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func isName(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isNameChar(s[i], i == 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func isNameChar(c byte, first bool) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || !first && '0' <= c && c <= '9'
+}
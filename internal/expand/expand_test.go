@@ -0,0 +1,60 @@
+package expand
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPathFunc(t *testing.T) {
+	env := map[string]string{
+		"XDG_CONFIG_HOME": "/home/me/.config",
+		"EMPTY":           "",
+		"NAME":            "nvim",
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"~", "/home/me"},
+		{"~/.config", "/home/me/.config"},
+		{"a/~/b", "a/~/b"},
+		{"$XDG_CONFIG_HOME/nvim", "/home/me/.config/nvim"},
+		{"${XDG_CONFIG_HOME}/$NAME", "/home/me/.config/nvim"},
+		{"${XDG_CONFIG_HOME:-~/.config}/nvim", "/home/me/.config/nvim"},
+		{"${UNSET:-.config}/nvim", ".config/nvim"},
+		{"${UNSET:-~/.config}/nvim", "/home/me/.config/nvim"},
+		{"${EMPTY:-.config}", ".config"},
+		{"${UNSET:-${NAME}}", "nvim"},
+		{"pre${EMPTY}post", "prepost"},
+		{"$$HOME", "$HOME"},
+		{"cost$", "cost$"},
+		{"a$-b", "a$-b"},
+		{"{1}/config", "{1}/config"},
+	}
+	for _, tt := range tests {
+		got, err := PathFunc(tt.in, "/home/me", lookup)
+		if err != nil {
+			t.Errorf("PathFunc(%q) failed: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("PathFunc(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"$UNSET/x", "${UNSET}", "${UNSET:-$ALSO_UNSET}"} {
+		if _, err := PathFunc(in, "/home/me", lookup); !errors.Is(err, ErrUndefined) {
+			t.Errorf("PathFunc(%q): expected ErrUndefined, got %v", in, err)
+		}
+	}
+	for _, in := range []string{"${UNCLOSED", "${1X}", "${}"} {
+		if _, err := PathFunc(in, "/home/me", lookup); err == nil || errors.Is(err, ErrUndefined) {
+			t.Errorf("PathFunc(%q): expected a syntax error, got %v", in, err)
+		}
+	}
+}
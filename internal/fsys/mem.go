@@ -0,0 +1,239 @@
+package fsys
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// MemFS is an in-memory FS for tests. Paths are cleaned with filepath.Clean
+// and symlinks in parent directories are not followed.
+type MemFS struct {
+	nodes map[string]*memNode
+}
+
+type memNode struct {
+	mode    fs.FileMode
+	data    []byte
+	target  string
+	modTime time.Time
+}
+
+func NewMemFS() *MemFS {
+	m := &MemFS{nodes: map[string]*memNode{}}
+	m.nodes["/"] = &memNode{mode: fs.ModeDir | 0755}
+	m.nodes["."] = &memNode{mode: fs.ModeDir | 0755}
+	return m
+}
+
+// WriteFile creates a regular file, creating parent directories as needed.
+func (m *MemFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	name = filepath.Clean(name)
+	if err := m.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	if n, ok := m.nodes[name]; ok && n.mode.IsDir() {
+		return &fs.PathError{Op: "open", Path: name, Err: syscall.EISDIR}
+	}
+	m.nodes[name] = &memNode{mode: perm, data: append([]byte(nil), data...), modTime: time.Now()}
+	return nil
+}
+
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	name = filepath.Clean(name)
+	for range 40 {
+		fi, err := m.Lstat(name)
+		if err != nil {
+			return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+		}
+		if fi.Mode()&fs.ModeSymlink == 0 {
+			return fi, nil
+		}
+		target := m.nodes[name].target
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(name), target)
+		}
+		name = filepath.Clean(target)
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: syscall.ELOOP}
+}
+
+func (m *MemFS) Lstat(name string) (fs.FileInfo, error) {
+	name = filepath.Clean(name)
+	if err := m.checkParent("lstat", name); err != nil {
+		return nil, err
+	}
+	n, ok := m.nodes[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: fs.ErrNotExist}
+	}
+	return memFileInfo{name: filepath.Base(name), node: n}, nil
+}
+
+func (m *MemFS) Readlink(name string) (string, error) {
+	name = filepath.Clean(name)
+	n, ok := m.nodes[name]
+	if !ok {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrNotExist}
+	}
+	if n.mode&fs.ModeSymlink == 0 {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: syscall.EINVAL}
+	}
+	return n.target, nil
+}
+
+func (m *MemFS) Symlink(oldname, newname string) error {
+	newname = filepath.Clean(newname)
+	if err := m.checkParent("symlink", newname); err != nil {
+		return err
+	}
+	if _, ok := m.nodes[newname]; ok {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: fs.ErrExist}
+	}
+	m.nodes[newname] = &memNode{mode: fs.ModeSymlink | 0777, target: oldname, modTime: time.Now()}
+	return nil
+}
+
+func (m *MemFS) Rename(oldpath, newpath string) error {
+	oldpath = filepath.Clean(oldpath)
+	newpath = filepath.Clean(newpath)
+	n, ok := m.nodes[oldpath]
+	if !ok {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrNotExist}
+	}
+	if err := m.checkParent("rename", newpath); err != nil {
+		return err
+	}
+	if dst, ok := m.nodes[newpath]; ok && dst.mode.IsDir() {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrExist}
+	}
+	for _, p := range m.descendants(oldpath) {
+		m.nodes[newpath+strings.TrimPrefix(p, oldpath)] = m.nodes[p]
+		delete(m.nodes, p)
+	}
+	delete(m.nodes, oldpath)
+	m.nodes[newpath] = n
+	return nil
+}
+
+func (m *MemFS) MkdirAll(name string, perm fs.FileMode) error {
+	name = filepath.Clean(name)
+	if n, ok := m.nodes[name]; ok {
+		if !n.mode.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: name, Err: syscall.ENOTDIR}
+		}
+		return nil
+	}
+	if parent := filepath.Dir(name); parent != name {
+		if err := m.MkdirAll(parent, perm); err != nil {
+			return err
+		}
+	}
+	m.nodes[name] = &memNode{mode: fs.ModeDir | perm, modTime: time.Now()}
+	return nil
+}
+
+func (m *MemFS) Remove(name string) error {
+	name = filepath.Clean(name)
+	n, ok := m.nodes[name]
+	if !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	if n.mode.IsDir() && len(m.descendants(name)) > 0 {
+		return &fs.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+	}
+	delete(m.nodes, name)
+	return nil
+}
+
+func (m *MemFS) RemoveAll(name string) error {
+	name = filepath.Clean(name)
+	for _, p := range m.descendants(name) {
+		delete(m.nodes, p)
+	}
+	delete(m.nodes, name)
+	return nil
+}
+
+func (m *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	name = filepath.Clean(name)
+	n, ok := m.nodes[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if !n.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdirent", Path: name, Err: syscall.ENOTDIR}
+	}
+	var entries []fs.DirEntry
+	for p, child := range m.nodes {
+		if p != name && filepath.Dir(p) == name {
+			entries = append(entries, fs.FileInfoToDirEntry(memFileInfo{name: filepath.Base(p), node: child}))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	name = filepath.Clean(name)
+	n, ok := m.nodes[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if n.mode.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: syscall.EISDIR}
+	}
+	if n.mode&fs.ModeSymlink != 0 {
+		target := n.target
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(name), target)
+		}
+		return m.ReadFile(target)
+	}
+	return append([]byte(nil), n.data...), nil
+}
+
+// checkParent reports an error when the parent of name is missing or is not
+// a directory.
+func (m *MemFS) checkParent(op, name string) error {
+	parent := filepath.Dir(name)
+	p, ok := m.nodes[parent]
+	if !ok {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	if !p.mode.IsDir() {
+		return &fs.PathError{Op: op, Path: name, Err: syscall.ENOTDIR}
+	}
+	return nil
+}
+
+// descendants returns every path below dir.
+func (m *MemFS) descendants(dir string) []string {
+	prefix := dir + string(filepath.Separator)
+	if dir == "/" {
+		prefix = dir
+	}
+	var result []string
+	for p := range m.nodes {
+		if p != dir && strings.HasPrefix(p, prefix) {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+type memFileInfo struct {
+	name string
+	node *memNode
+}
+
+func (fi memFileInfo) Name() string       { return fi.name }
+func (fi memFileInfo) Size() int64        { return int64(len(fi.node.data)) }
+func (fi memFileInfo) Mode() fs.FileMode  { return fi.node.mode }
+func (fi memFileInfo) ModTime() time.Time { return fi.node.modTime }
+func (fi memFileInfo) IsDir() bool        { return fi.node.mode.IsDir() }
+func (fi memFileInfo) Sys() any           { return nil }
@@ -0,0 +1,278 @@
+// Package glob implements the wildcard patterns of index files.
+//
+// Patterns are slash-separated and support:
+//
+//	syntax  matches
+//	*       any sequence of characters except '/'
+//	?       any single character except '/'
+//	[abc]   a character class, [!abc] or [^abc] negates it
+//	{a,b}   any of the comma-separated alternatives
+//	**      a whole segment matching zero or more directories
+//	\x      the literal character x
+package glob
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/hidakatsuya/flexdot-go/internal/fsys"
+)
+
+// Pattern is a compiled glob pattern.
+type Pattern struct {
+	raw       string
+	re        *regexp.Regexp
+	base      string // literal directory before the first wildcard segment
+	depth     int    // number of segments after base
+	recursive bool   // whether the pattern contains a ** segment
+}
+
+// meta are the characters starting wildcard syntax.
+const meta = `*?[{\`
+
+// HasMeta reports whether s contains any wildcard syntax.
+func HasMeta(s string) bool {
+	return strings.ContainsAny(s, meta)
+}
+
+// Escape returns s with its wildcard syntax escaped, as a pattern matching
+// only s itself.
+func Escape(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		if strings.ContainsRune(meta, c) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// Compile parses a glob pattern.
+func Compile(pattern string) (*Pattern, error) {
+	segments := strings.Split(pattern, "/")
+	p := &Pattern{raw: pattern}
+
+	var baseSegments []string
+	for len(segments) > 0 && !HasMeta(segments[0]) {
+		baseSegments = append(baseSegments, segments[0])
+		segments = segments[1:]
+	}
+	p.base = strings.Join(baseSegments, "/")
+	p.depth = len(segments)
+
+	var re strings.Builder
+	re.WriteString("^")
+	if p.base != "" {
+		re.WriteString(regexp.QuoteMeta(p.base))
+		if len(segments) > 0 {
+			re.WriteString("/")
+		}
+	}
+	for i, seg := range segments {
+		last := i == len(segments)-1
+		if seg == "**" {
+			p.recursive = true
+			if last {
+				re.WriteString("(.*)")
+			} else {
+				re.WriteString("((?:[^/]+/)*)")
+			}
+			continue
+		}
+		if err := translateSegment(&re, seg, true); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		if !last {
+			re.WriteString("/")
+		}
+	}
+	re.WriteString("$")
+
+	compiled, err := regexp.Compile(re.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	p.re = compiled
+	return p, nil
+}
+
+// translateSegment writes the regular expression for a single segment.
+// Wildcards become capturing groups when capture is true.
+func translateSegment(re *strings.Builder, seg string, capture bool) error {
+	open, close := "(", ")"
+	if !capture {
+		open = "(?:"
+	}
+	for i := 0; i < len(seg); i++ {
+		switch c := seg[i]; c {
+		case '*':
+			re.WriteString(open + "[^/]*" + close)
+		case '?':
+			re.WriteString(open + "[^/]" + close)
+		case '\\':
+			if i+1 >= len(seg) {
+				return fmt.Errorf("trailing backslash")
+			}
+			i++
+			re.WriteString(regexp.QuoteMeta(seg[i : i+1]))
+		case '[':
+			end := strings.IndexByte(seg[i+1:], ']')
+			if end < 0 {
+				return fmt.Errorf("unclosed character class")
+			}
+			class := seg[i+1 : i+1+end]
+			if class == "" {
+				return fmt.Errorf("empty character class")
+			}
+			if class[0] == '!' {
+				class = "^" + class[1:]
+			}
+			re.WriteString(open + "[" + strings.ReplaceAll(class, `\`, `\\`) + "]" + close)
+			i += end + 1
+		case '{':
+			end := matchingBrace(seg, i)
+			if end < 0 {
+				return fmt.Errorf("unclosed brace")
+			}
+			re.WriteString(open)
+			for j, alt := range splitAlternatives(seg[i+1 : end]) {
+				if j > 0 {
+					re.WriteString("|")
+				}
+				if err := translateSegment(re, alt, false); err != nil {
+					return err
+				}
+			}
+			re.WriteString(")")
+			i = end
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return nil
+}
+
+// matchingBrace returns the index of the '}' closing the '{' at start.
+func matchingBrace(s string, start int) int {
+	depth := 0
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitAlternatives splits the body of a brace on its top-level commas.
+func splitAlternatives(s string) []string {
+	var alts []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				alts = append(alts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(alts, s[start:])
+}
+
+// String returns the source of the pattern.
+func (p *Pattern) String() string {
+	return p.raw
+}
+
+// Base returns the literal directory before the first wildcard segment.
+func (p *Pattern) Base() string {
+	return p.base
+}
+
+// Match reports whether the slash-separated path matches the pattern.
+func (p *Pattern) Match(name string) bool {
+	return p.re.MatchString(name)
+}
+
+// Captures returns the text matched by each wildcard of the pattern, in
+// order, or nil when name does not match. A brace group counts as a single
+// wildcard. The trailing slash of a ** segment followed by more segments is
+// dropped.
+func (p *Pattern) Captures(name string) []string {
+	m := p.re.FindStringSubmatch(name)
+	if m == nil {
+		return nil
+	}
+	captures := m[1:]
+	for i, c := range captures {
+		captures[i] = strings.TrimSuffix(c, "/")
+	}
+	return captures
+}
+
+// NumCaptures returns the number of wildcards of the pattern.
+func (p *Pattern) NumCaptures() int {
+	return p.re.NumSubexp()
+}
+
+// Expand returns the slash-separated paths below root, relative to it, that
+// match the pattern, in lexical order. Recursive patterns only match
+// non-directories. .git directories are never matched or descended into, and
+// neither are paths for which skip, when not nil, returns true.
+func (p *Pattern) Expand(filesystem fsys.FS, root string, skip func(rel string) bool) ([]string, error) {
+	var matches []string
+	var walk func(rel string, depth int) error
+	walk = func(rel string, depth int) error {
+		entries, err := filesystem.ReadDir(filepath.Join(root, filepath.FromSlash(rel)))
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			child := path.Join(rel, entry.Name())
+			if entry.Name() == ".git" || skip != nil && skip(child) {
+				continue
+			}
+			isDir := entry.IsDir()
+			if p.Match(child) && (!p.recursive || !isDir) {
+				matches = append(matches, child)
+			}
+			if isDir && (p.recursive || depth+1 < p.depth) {
+				if err := walk(child, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := walk(p.base, 0); err != nil {
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ENOTDIR) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
@@ -0,0 +1,148 @@
+package glob
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hidakatsuya/flexdot-go/internal/fsys"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"*.md", "code.md", true},
+		{"*.md", "dir/code.md", false},
+		{"prompts/*.md", "prompts/code.md", true},
+		{"*/config/*.toml", "app/config/a.toml", true},
+		{"*/config/*.toml", "app/other/a.toml", false},
+		{"**/*.md", "a.md", true},
+		{"**/*.md", "a/b/c.md", true},
+		{"docs/**", "docs/a/b", true},
+		{"file?.txt", "file1.txt", true},
+		{"file?.txt", "file10.txt", false},
+		{"[ab].txt", "a.txt", true},
+		{"[!ab].txt", "a.txt", false},
+		{"[!ab].txt", "c.txt", true},
+		{"*.{yml,yaml}", "a.yaml", true},
+		{"*.{yml,yaml}", "a.json", false},
+		{"{bash,zsh}/*rc", "zsh/.zshrc", true},
+		{`\*.md`, "*.md", true},
+		{`\*.md`, "a.md", false},
+	}
+	for _, tt := range tests {
+		p, err := Compile(tt.pattern)
+		if err != nil {
+			t.Fatalf("Compile(%q) failed: %v", tt.pattern, err)
+		}
+		if got := p.Match(tt.name); got != tt.want {
+			t.Errorf("%q.Match(%q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEscape(t *testing.T) {
+	for _, name := range []string{"foo[1].toml", `a*b?{c,d}\e`, "plain.txt"} {
+		p, err := Compile(Escape(name))
+		if err != nil {
+			t.Fatalf("Compile(Escape(%q)) failed: %v", name, err)
+		}
+		if !p.Match(name) {
+			t.Errorf("Escape(%q) = %q does not match itself", name, Escape(name))
+		}
+		if p.Match(name + "x") {
+			t.Errorf("Escape(%q) = %q matches more than itself", name, Escape(name))
+		}
+	}
+	if p, _ := Compile(Escape("foo[1].toml")); p.Match("foo1.toml") {
+		t.Errorf("escaped class matches foo1.toml")
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, pattern := range []string{"[abc", "{a,b", `a\`, "[]"} {
+		if _, err := Compile(pattern); err == nil {
+			t.Errorf("Compile(%q) should fail", pattern)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	mem := fsys.NewMemFS()
+	for _, name := range []string{
+		"/repo/notes/a.md",
+		"/repo/notes/sub/b.md",
+		"/repo/notes/sub/c.txt",
+		"/repo/apps/x/config/x.toml",
+		"/repo/apps/y/config/y.toml",
+		"/repo/.git/HEAD.md",
+	} {
+		mem.WriteFile(name, nil, 0644)
+	}
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"notes/*.md", []string{"notes/a.md"}},
+		{"notes/**/*.md", []string{"notes/a.md", "notes/sub/b.md"}},
+		{"apps/*/config/*.toml", []string{"apps/x/config/x.toml", "apps/y/config/y.toml"}},
+		{"notes/*", []string{"notes/a.md", "notes/sub"}},
+		{"**/HEAD.md", nil},
+		{"*", []string{"apps", "notes"}},
+		{"missing/*", nil},
+	}
+	for _, tt := range tests {
+		p, err := Compile(tt.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := p.Expand(mem, "/repo", nil)
+		if err != nil {
+			t.Fatalf("Expand(%q) failed: %v", tt.pattern, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Expand(%q) = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+
+	// Skipped directories are not walked
+	p, err := Compile("**/*.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := p.Expand(mem, "/repo", func(rel string) bool { return rel == "apps/x" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"apps/y/config/y.toml"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expand with skip = %v, want %v", got, want)
+	}
+}
+
+func TestCaptures(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    []string
+	}{
+		{"apps/*/config.toml", "apps/foo/config.toml", []string{"foo"}},
+		{"*/config/*.toml", "app/config/a.toml", []string{"app", "a"}},
+		{"**/*.md", "a/b/c.md", []string{"a/b", "c"}},
+		{"**/*.md", "c.md", []string{"", "c"}},
+		{"{bash,zsh}/*rc", "zsh/.zshrc", []string{"zsh", ".zsh"}},
+		{"file?.txt", "file1.txt", []string{"1"}},
+		{"apps/*/config.toml", "apps/foo/other.toml", nil},
+	}
+	for _, tt := range tests {
+		p, err := Compile(tt.pattern)
+		if err != nil {
+			t.Fatalf("Compile(%q) failed: %v", tt.pattern, err)
+		}
+		if got := p.Captures(tt.name); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Captures(%q, %q) = %q, want %q", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
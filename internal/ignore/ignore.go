@@ -0,0 +1,115 @@
+// Package ignore matches paths against gitignore-style patterns, as used by
+// .flexdotignore files and the exclude option of index entries.
+package ignore
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/hidakatsuya/flexdot-go/internal/fsys"
+	"github.com/hidakatsuya/flexdot-go/internal/glob"
+)
+
+// FileName is the name of the ignore file at the root of the dotfiles dir.
+const FileName = ".flexdotignore"
+
+// Matcher holds an ordered list of ignore rules. The zero value and nil
+// ignore nothing.
+type Matcher struct {
+	rules []rule
+}
+
+type rule struct {
+	pattern *glob.Pattern
+	negate  bool
+	dirOnly bool
+}
+
+// Load reads the .flexdotignore file in dir. A missing file yields an empty
+// Matcher.
+func Load(filesystem fsys.FS, dir string) (*Matcher, error) {
+	data, err := filesystem.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return &Matcher{}, nil
+		}
+		return nil, err
+	}
+	m, err := New(strings.Split(string(data), "\n"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", FileName, err)
+	}
+	return m, nil
+}
+
+// New compiles gitignore-style pattern lines. Blank lines and lines starting
+// with '#' are skipped.
+func New(lines []string) (*Matcher, error) {
+	m := &Matcher{}
+	for i, line := range lines {
+		line = strings.TrimRight(strings.TrimSuffix(line, "\r"), " ")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var r rule
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = line[1:]
+		}
+		if strings.HasPrefix(line, `\`) && len(line) > 1 && (line[1] == '#' || line[1] == '!') {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if strings.HasPrefix(line, "/") {
+			line = strings.TrimPrefix(line, "/")
+		} else if !strings.Contains(line, "/") {
+			// Patterns without a slash match at any level
+			line = "**/" + line
+		}
+
+		pattern, err := glob.Compile(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		r.pattern = pattern
+		m.rules = append(m.rules, r)
+	}
+	return m, nil
+}
+
+// Match reports whether the slash-separated path is ignored. As with git, a
+// path inside an ignored directory is ignored as well.
+func (m *Matcher) Match(name string, isDir bool) bool {
+	if m == nil || len(m.rules) == 0 {
+		return false
+	}
+	name = path.Clean(name)
+	for dir := path.Dir(name); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if m.match(dir, true) {
+			return true
+		}
+	}
+	return m.match(name, isDir)
+}
+
+// match applies the rules to name alone; the last matching rule wins.
+func (m *Matcher) match(name string, isDir bool) bool {
+	ignored := false
+	for _, r := range m.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		if r.pattern.Match(name) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,53 @@
+package ignore
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	m, err := New([]string{
+		"# comment",
+		"",
+		"*.swp",
+		".DS_Store",
+		"/README.md",
+		"build/",
+		"docs/*.txt",
+		"!docs/keep.txt",
+		`\#hash`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		isDir bool
+		want  bool
+	}{
+		{"a.swp", false, true},
+		{"deep/dir/a.swp", false, true},
+		{"macOS/.DS_Store", false, true},
+		{"README.md", false, true},
+		{"sub/README.md", false, false},
+		{"build", true, true},
+		{"build", false, false},
+		{"build/out.txt", false, true},
+		{"src/build/out.txt", false, true},
+		{"docs/a.txt", false, true},
+		{"docs/keep.txt", false, false},
+		{"docs/sub/a.txt", false, false},
+		{"#hash", false, true},
+		{"main.go", false, false},
+	}
+	for _, tt := range tests {
+		if got := m.Match(tt.name, tt.isDir); got != tt.want {
+			t.Errorf("Match(%q, %v) = %v, want %v", tt.name, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestNilMatcher(t *testing.T) {
+	var m *Matcher
+	if m.Match("a", false) {
+		t.Error("nil Matcher should ignore nothing")
+	}
+}
@@ -1,24 +1,78 @@
 package initcmd
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
 
 	"github.com/hidakatsuya/flexdot-go/internal/config"
 	"gopkg.in/yaml.v3"
 )
 
-func Run() error {
-	configPath := filepath.Join(".", "config.yml")
+type Options struct {
+	DotfilesDir  string
+	HomeDir      string // empty means the user's home directory
+	Index        string // index file to set in config.yml
+	KeepMaxCount int    // -1 keeps the default
+	Scaffold     bool   // also create a starter index file and .gitignore
+
+	Interactive bool // whether In can be used to ask for the values not set
+	In          io.Reader
+	Out         io.Writer
+}
+
+// starterIndex is the content of the index file created by Scaffold.
+const starterIndex = `# Index of the dotfiles to link.
+#
+# Each key is a path in this repository and each value the directory in
+# your home directory to link it into, for example:
+#
+# bash:
+#   .bashrc: .
+# nvim:
+#   init.lua: .config/nvim
+`
+
+// gitignoreLines are the entries Scaffold ensures in .gitignore.
+var gitignoreLines = []string{"backup/", "config.local.yml"}
+
+// Run creates a config.yml in opts.DotfilesDir and, with opts.Scaffold, a
+// starter index file and .gitignore.
+func Run(opts Options) error {
+	configPath := filepath.Join(opts.DotfilesDir, "config.yml")
 
 	// Check if config.yml already exists
 	if _, err := os.Stat(configPath); err == nil {
 		return fmt.Errorf("config.yml already exists in this directory")
 	}
 
-	cfg := config.DefaultConfig()
-	yml, err := yaml.Marshal(&cfg)
+	if opts.KeepMaxCount < -1 {
+		return fmt.Errorf("keep_max_count must be 0 or more, got %d", opts.KeepMaxCount)
+	}
+	if err := newConfig(opts).Validate(opts.DotfilesDir); err != nil {
+		return err
+	}
+	if opts.Interactive {
+		if err := ask(&opts); err != nil {
+			return err
+		}
+	}
+	if opts.Scaffold && opts.Index == "" {
+		opts.Index = ProposeIndexName()
+	}
+
+	cfg := newConfig(opts)
+	if err := cfg.Validate(opts.DotfilesDir); err != nil {
+		return err
+	}
+	yml, err := yaml.Marshal(cfg)
 	if err != nil {
 		return fmt.Errorf("failed to marshal default config: %w", err)
 	}
@@ -26,7 +80,190 @@ func Run() error {
 	if err := os.WriteFile(configPath, yml, 0644); err != nil {
 		return fmt.Errorf("failed to write config.yml: %w", err)
 	}
+	if opts.HomeDir == "" && opts.Index == "" && opts.KeepMaxCount < 0 {
+		fmt.Fprintln(opts.Out, "Created config.yml with default values.")
+	} else {
+		fmt.Fprintln(opts.Out, "Created config.yml.")
+	}
+
+	if !opts.Scaffold {
+		return nil
+	}
+	if err := writeIndex(opts); err != nil {
+		return err
+	}
+	return updateGitignore(opts)
+}
+
+// newConfig returns the config to write for opts.
+func newConfig(opts Options) *config.Config {
+	cfg := config.DefaultConfig()
+	cfg.HomeDir = opts.HomeDir
+	cfg.IndexYml = opts.Index
+	if opts.KeepMaxCount >= 0 {
+		cfg.KeepMaxCount = &opts.KeepMaxCount
+	}
+	return &cfg
+}
+
+// ask prompts for the options that were not set by flags, asking again
+// after an invalid answer.
+func ask(opts *Options) error {
+	in := bufio.NewReader(opts.In)
+	check := func(cfg config.Config) error {
+		return cfg.Validate(opts.DotfilesDir)
+	}
+	if opts.HomeDir == "" {
+		answer, err := askValid(in, opts.Out, "Home directory", "your home directory", "", func(answer string) error {
+			return check(config.Config{HomeDir: answer})
+		})
+		if err != nil {
+			return err
+		}
+		opts.HomeDir = answer
+	}
+	if !opts.Scaffold {
+		answer := prompt(in, opts.Out, "Create a starter index file and .gitignore? [y/N]", "", "n")
+		opts.Scaffold = strings.EqualFold(answer, "y") || strings.EqualFold(answer, "yes")
+	}
+	if opts.Index == "" {
+		// Only the starter index file has a name by default; the hint shows
+		// what an empty answer gives
+		def := ""
+		if opts.Scaffold {
+			def = ProposeIndexName()
+		}
+		answer, err := askValid(in, opts.Out, "Index file", def, def, func(answer string) error {
+			return check(config.Config{IndexYml: answer})
+		})
+		if err != nil {
+			return err
+		}
+		opts.Index = answer
+	}
+	if opts.KeepMaxCount < 0 {
+		def := strconv.Itoa(*config.DefaultConfig().KeepMaxCount)
+		answer, err := askValid(in, opts.Out, "Number of backups to keep", def, def, func(answer string) error {
+			n, err := strconv.Atoi(answer)
+			if err != nil {
+				return fmt.Errorf("keep_max_count must be a number, got %q", answer)
+			}
+			return check(config.Config{KeepMaxCount: &n})
+		})
+		if err != nil {
+			return err
+		}
+		opts.KeepMaxCount, _ = strconv.Atoi(answer)
+	}
+	return nil
+}
+
+// askValid prompts until valid accepts the answer. It gives up with the
+// error of the default, which is also the answer at the end of the input.
+func askValid(in *bufio.Reader, out io.Writer, question, hint, def string, valid func(string) error) (string, error) {
+	for {
+		answer := prompt(in, out, question, hint, def)
+		err := valid(answer)
+		if err == nil || answer == def {
+			return answer, err
+		}
+		fmt.Fprintf(out, "Invalid answer: %v\n", err)
+	}
+}
 
-	fmt.Println("Created config.yml with default values.")
+// prompt asks question and returns the answer, or def for an empty answer
+// or at the end of the input. hint is shown as the suggested value.
+func prompt(in *bufio.Reader, out io.Writer, question, hint, def string) string {
+	if hint != "" {
+		fmt.Fprintf(out, "%s (%s): ", question, hint)
+	} else {
+		fmt.Fprintf(out, "%s: ", question)
+	}
+	answer, err := in.ReadString('\n')
+	answer = strings.TrimSpace(answer)
+	if err != nil {
+		fmt.Fprintln(out)
+	}
+	if answer == "" {
+		return def
+	}
+	return answer
+}
+
+// ProposeIndexName returns an index file name for the running OS, such as
+// macOS.yml or ubuntu.yml.
+func ProposeIndexName() string {
+	osRelease, _ := os.ReadFile("/etc/os-release")
+	return indexNameFor(runtime.GOOS, osRelease)
+}
+
+// indexNameFor returns the index file name for goos, using the ID of the
+// os-release file on Linux.
+func indexNameFor(goos string, osRelease []byte) string {
+	switch goos {
+	case "darwin":
+		return "macOS.yml"
+	case "linux":
+		for _, line := range strings.Split(string(osRelease), "\n") {
+			if id, ok := strings.CutPrefix(line, "ID="); ok {
+				if id = strings.Trim(id, `"' `); id != "" {
+					return id + ".yml"
+				}
+			}
+		}
+	}
+	return goos + ".yml"
+}
+
+// writeIndex creates the starter index file unless it exists.
+func writeIndex(opts Options) error {
+	path := opts.Index
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(opts.DotfilesDir, path)
+	}
+	if _, err := os.Stat(path); err == nil {
+		fmt.Fprintf(opts.Out, "Kept existing %s.\n", opts.Index)
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", opts.Index, err)
+	}
+	if err := os.WriteFile(path, []byte(starterIndex), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", opts.Index, err)
+	}
+	fmt.Fprintf(opts.Out, "Created %s.\n", opts.Index)
+	return nil
+}
+
+// updateGitignore adds the missing gitignoreLines to .gitignore.
+func updateGitignore(opts Options) error {
+	path := filepath.Join(opts.DotfilesDir, ".gitignore")
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read .gitignore: %w", err)
+	}
+
+	existing := map[string]bool{}
+	for _, line := range strings.Split(string(data), "\n") {
+		existing[strings.TrimSpace(line)] = true
+	}
+	var missing []string
+	for _, line := range gitignoreLines {
+		if !existing[line] {
+			missing = append(missing, line)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if len(data) > 0 && !strings.HasSuffix(string(data), "\n") {
+		data = append(data, '\n')
+	}
+	data = append(data, strings.Join(missing, "\n")+"\n"...)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write .gitignore: %w", err)
+	}
+	fmt.Fprintln(opts.Out, "Updated .gitignore.")
 	return nil
 }
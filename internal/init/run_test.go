@@ -0,0 +1,165 @@
+package initcmd
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIndexNameFor(t *testing.T) {
+	tests := []struct {
+		goos      string
+		osRelease string
+		want      string
+	}{
+		{"darwin", "", "macOS.yml"},
+		{"linux", "NAME=\"Ubuntu\"\nID=ubuntu\nID_LIKE=debian\n", "ubuntu.yml"},
+		{"linux", "ID=\"fedora\"\n", "fedora.yml"},
+		{"linux", "", "linux.yml"},
+		{"windows", "", "windows.yml"},
+		{"freebsd", "", "freebsd.yml"},
+	}
+	for _, tt := range tests {
+		if got := indexNameFor(tt.goos, []byte(tt.osRelease)); got != tt.want {
+			t.Errorf("indexNameFor(%q, %q) = %q, want %q", tt.goos, tt.osRelease, got, tt.want)
+		}
+	}
+}
+
+func TestRunInteractive(t *testing.T) {
+	dir := t.TempDir()
+	err := Run(Options{
+		DotfilesDir:  dir,
+		KeepMaxCount: -1,
+		Interactive:  true,
+		In:           strings.NewReader("/home/me\ny\nmine.yml\n5\n"),
+		Out:          io.Discard,
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "config.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"home_dir: /home/me", "index_yml: mine.yml", "keep_max_count: 5"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("config.yml missing %q, got:\n%s", want, data)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "mine.yml")); err != nil {
+		t.Errorf("starter index file was not created: %v", err)
+	}
+}
+
+func TestRunInteractiveDefaults(t *testing.T) {
+	// Answers that end early keep the default values
+	dir := t.TempDir()
+	err := Run(Options{
+		DotfilesDir:  dir,
+		Index:        "set.yml",
+		KeepMaxCount: -1,
+		Interactive:  true,
+		In:           strings.NewReader("\n"),
+		Out:          io.Discard,
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "config.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"home_dir: \"\"", "index_yml: set.yml", "keep_max_count: 10"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("config.yml missing %q, got:\n%s", want, data)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".gitignore")); err == nil {
+		t.Errorf(".gitignore should not be created without scaffolding")
+	}
+}
+
+func TestRunInteractiveIndexHint(t *testing.T) {
+	// The hint is the value an empty answer gives
+	for _, scaffold := range []bool{false, true} {
+		dir := t.TempDir()
+		var out strings.Builder
+		err := Run(Options{DotfilesDir: dir, HomeDir: "/home/me", KeepMaxCount: 3, Scaffold: scaffold, Interactive: true, In: strings.NewReader("\n"), Out: &out})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		want := "Index file: "
+		if scaffold {
+			want = "Index file (" + ProposeIndexName() + "): "
+		}
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("scaffold %v: expected the prompt %q, got:\n%s", scaffold, want, out.String())
+		}
+	}
+}
+
+func TestRunInteractiveAsksAgain(t *testing.T) {
+	dir := t.TempDir()
+	var out strings.Builder
+	err := Run(Options{
+		DotfilesDir:  dir,
+		KeepMaxCount: -1,
+		Interactive:  true,
+		In:           strings.NewReader("relative/home\n/home/me\n\nconf/\nconf.yml\nmany\n-1\n3\n"),
+		Out:          &out,
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	for _, want := range []string{
+		`Invalid answer: home_dir must be an absolute path, got "relative/home"`,
+		`Invalid answer: index_yml must be a file, got "conf/"`,
+		`Invalid answer: keep_max_count must be a number, got "many"`,
+		`Invalid answer: keep_max_count must be 0 or more, got -1`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q, got:\n%s", want, out.String())
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "config.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"home_dir: /home/me", "index_yml: conf.yml", "keep_max_count: 3"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("config.yml missing %q, got:\n%s", want, data)
+		}
+	}
+}
+
+func TestRunRejectsInvalidValues(t *testing.T) {
+	for _, opts := range []Options{
+		{HomeDir: "relative/home", KeepMaxCount: -1},
+		{HomeDir: "$FLEXDOT_TEST_UNSET_HOME/x", KeepMaxCount: -1},
+		{Index: "conf/", KeepMaxCount: -1},
+	} {
+		opts.DotfilesDir = t.TempDir()
+		opts.Out = io.Discard
+		if err := Run(opts); err == nil {
+			t.Errorf("Run(%+v) succeeded, want an error", opts)
+		}
+		if _, err := os.Stat(filepath.Join(opts.DotfilesDir, "config.yml")); err == nil {
+			t.Errorf("Run(%+v) wrote config.yml", opts)
+		}
+	}
+
+	// ~ is expanded before the check and written as it is
+	dir := t.TempDir()
+	if err := Run(Options{DotfilesDir: dir, HomeDir: "~/sandbox", KeepMaxCount: -1, Out: io.Discard}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "config.yml")); err != nil || !strings.Contains(string(data), "home_dir: ~/sandbox") {
+		t.Errorf("config.yml should keep ~/sandbox, got:\n%s (%v)", data, err)
+	}
+}
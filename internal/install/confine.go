@@ -0,0 +1,112 @@
+package install
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hidakatsuya/flexdot-go/internal/fsys"
+)
+
+var (
+	ErrOutsideHomeDir     = errors.New("destination is outside the home directory")
+	ErrOutsideDotfilesDir = errors.New("source is outside the dotfiles directory")
+	ErrAbsoluteHomeFile   = errors.New("absolute destination outside the home directory requires allow_outside_home")
+	ErrHomeDirDestination = errors.New("destination is the home directory itself")
+	ErrNotReplaceable     = errors.New("a directory or special file is in the way")
+)
+
+// checkConfinement verifies that dotfile stays inside the dotfiles dir and
+// homeFile inside the home dir, both lexically and after resolving the
+// symlinks of their existing parent directories.
+func (in *Installer) checkConfinement(dotfile, homeFile string) error {
+	if err := confine(in.fs, in.opts.DotfilesDir, dotfile); err != nil {
+		return fmt.Errorf("%w: %s", ErrOutsideDotfilesDir, err)
+	}
+	if in.opts.AllowOutsideHome {
+		return nil
+	}
+	if err := confine(in.fs, in.opts.HomeDir, homeFile); err != nil {
+		return fmt.Errorf("%w: %s", ErrOutsideHomeDir, err)
+	}
+	return nil
+}
+
+// confine returns an error when path is not inside dir.
+func confine(fs fsys.FS, dir, path string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if !isWithin(dir, path) {
+		return errors.New(path)
+	}
+
+	resolvedDir, err := evalSymlinks(fs, dir)
+	if err != nil {
+		return err
+	}
+	resolvedParent, err := evalSymlinks(fs, filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	if !isWithin(resolvedDir, filepath.Join(resolvedParent, filepath.Base(path))) {
+		return fmt.Errorf("%s resolves to %s", path, resolvedParent)
+	}
+	return nil
+}
+
+// isWithin reports whether path is dir or below it. Both must be clean.
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// evalSymlinks is filepath.EvalSymlinks for an absolute path read through fs,
+// except that a missing component ends the resolution and the remaining
+// components are appended as they are.
+func evalSymlinks(fs fsys.FS, path string) (string, error) {
+	resolved := string(filepath.Separator)
+	rest := strings.Split(strings.TrimPrefix(filepath.Clean(path), string(filepath.Separator)), string(filepath.Separator))
+
+	for hops := 0; len(rest) > 0; {
+		name := rest[0]
+		rest = rest[1:]
+		if name == "" {
+			continue
+		}
+		next := filepath.Join(resolved, name)
+
+		fi, err := fs.Lstat(next)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return filepath.Join(append([]string{next}, rest...)...), nil
+			}
+			return "", err
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		hops++
+		if hops > 255 {
+			return "", fmt.Errorf("too many links in %s", path)
+		}
+		target, err := fs.Readlink(next)
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			resolved = string(filepath.Separator)
+		}
+		rest = append(strings.Split(filepath.Clean(target), string(filepath.Separator)), rest...)
+	}
+	return resolved, nil
+}
@@ -0,0 +1,45 @@
+package install
+
+import (
+	"fmt"
+)
+
+// EntryError describes a failure while installing a single index entry.
+type EntryError struct {
+	Entry Entry
+	Op    string
+	Err   error // usually *os.PathError or *os.LinkError
+}
+
+func (e *EntryError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Op, e.Entry.DotfilePath, e.Err)
+}
+
+func (e *EntryError) Unwrap() error {
+	return e.Err
+}
+
+// InstallError collects every EntryError encountered during an install.
+type InstallError struct {
+	Errors []*EntryError
+}
+
+func (e *InstallError) Error() string {
+	if len(e.Errors) == 1 {
+		return "encountered 1 error during install"
+	}
+	return fmt.Sprintf("encountered %d errors during install", len(e.Errors))
+}
+
+// Unwrap allows errors.Is and errors.As to inspect each entry error.
+func (e *InstallError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+func entryError(entry Entry, op string, err error) *EntryError {
+	return &EntryError{Entry: entry, Op: op, Err: err}
+}
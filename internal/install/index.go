@@ -0,0 +1,260 @@
+package install
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hidakatsuya/flexdot-go/internal/expand"
+	"github.com/hidakatsuya/flexdot-go/internal/fsys"
+	"github.com/hidakatsuya/flexdot-go/internal/glob"
+	"github.com/hidakatsuya/flexdot-go/internal/ignore"
+)
+
+// ErrNoMatches is reported for a wildcard that matches no dotfiles.
+var ErrNoMatches = errors.New("wildcard matches no files")
+
+// ErrDuplicateDestination is returned for two entries linking the same path.
+var ErrDuplicateDestination = errors.New("entries link the same destination")
+
+// flattener turns the nested index map into entries.
+type flattener struct {
+	fs          fsys.FS
+	dotfilesDir string
+	ignore      *ignore.Matcher // .flexdotignore, consulted by wildcard expansion
+	backupDir   string          // backup dir relative to dotfilesDir, skipped by wildcards; "" when outside
+	lookup      expand.LookupFunc
+	result      []Entry
+	noMatches   []error // an ErrNoMatches for each wildcard without entries
+}
+
+// FlattenIndex traverses the index map and returns a slice of dotfile/homefile
+// path pairs, along with an ErrNoMatches for each wildcard that matched
+// nothing, sorted by pattern. Wildcards never match files in backupDir.
+func flattenIndex(fs fsys.FS, idx map[string]any, dotfilesDir, backupDir string, vars map[string]string) ([]Entry, []error, error) {
+	ignored, err := ignore.Load(fs, dotfilesDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	f := &flattener{fs: fs, dotfilesDir: dotfilesDir, ignore: ignored, lookup: expand.WithVars(vars)}
+	if rel, err := filepath.Rel(dotfilesDir, backupDir); err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		f.backupDir = filepath.ToSlash(rel)
+	}
+	for root, descendants := range idx {
+		if err := f.flattenDescendants(descendants, []string{root}); err != nil {
+			return nil, nil, err
+		}
+	}
+	sort.Slice(f.noMatches, func(i, j int) bool {
+		return f.noMatches[i].Error() < f.noMatches[j].Error()
+	})
+	return f.result, f.noMatches, nil
+}
+
+func (f *flattener) flattenDescendants(descendants any, paths []string) error {
+	switch v := descendants.(type) {
+	case map[string]any:
+		if opts, ok := parseLeafOptions(v); ok {
+			return f.flattenLeaf(paths, opts)
+		}
+		for k, val := range v {
+			newPaths := append(paths, k)
+			if err := f.flattenDescendants(val, newPaths); err != nil {
+				return err
+			}
+		}
+	case string:
+		return f.flattenLeaf(paths, leafOptions{To: v, IsDir: true})
+	}
+	return nil
+}
+
+// leafOptions is the value of an index leaf. It is either a plain string,
+// the directory to link into, or a mapping of entry options such as
+// `bashrc: {to: .bashrc}`.
+type leafOptions struct {
+	To           string   // destination, relative to the home directory
+	IsDir        bool     // whether To is a directory rather than the link path
+	PreserveDirs bool     // keep the subdirectories matched by a wildcard under To
+	Exclude      []string // gitignore-style patterns dropped from wildcard matches
+}
+
+// leafOptionKeys are the keys of the mapping of entry options.
+var leafOptionKeys = []string{"to", "preserve_dirs", "exclude"}
+
+// IsLeafOptionKey reports whether key is an entry option, such as the to of
+// `bashrc: {to: .bashrc}`, rather than a path of the index.
+func IsLeafOptionKey(key string) bool {
+	return slices.Contains(leafOptionKeys, key)
+}
+
+// parseLeafOptions reads a mapping of entry options. It returns false when v
+// has keys other than entry options, meaning it is a directory of the index.
+func parseLeafOptions(v map[string]any) (leafOptions, bool) {
+	opts := leafOptions{To: ".", IsDir: true}
+	if len(v) == 0 {
+		return opts, false
+	}
+	for key, val := range v {
+		if !IsLeafOptionKey(key) {
+			return opts, false
+		}
+		switch key {
+		case "to":
+			to, ok := val.(string)
+			if !ok {
+				return opts, false
+			}
+			opts.To = to
+			opts.IsDir = strings.HasSuffix(to, "/")
+		case "preserve_dirs":
+			preserve, ok := val.(bool)
+			if !ok {
+				return opts, false
+			}
+			opts.PreserveDirs = preserve
+		case "exclude":
+			exclude, ok := stringList(val)
+			if !ok {
+				return opts, false
+			}
+			opts.Exclude = exclude
+		}
+	}
+	return opts, true
+}
+
+// stringList accepts a string or a list of strings.
+func stringList(v any) ([]string, bool) {
+	switch v := v.(type) {
+	case string:
+		return []string{v}, true
+	case []any:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			list = append(list, s)
+		}
+		return list, true
+	}
+	return nil, false
+}
+
+// flattenLeaf adds the entries for the dotfile at paths. Keys containing
+// wildcards are expanded against the dotfiles dir. A leading ~ and environment
+// variables in the destination are expanded, ~ meaning the home directory.
+func (f *flattener) flattenLeaf(paths []string, opts leafOptions) error {
+	to, err := expand.PathFunc(opts.To, ".", f.lookup)
+	if err != nil {
+		return fmt.Errorf("%s: %w", strings.Join(paths, "/"), err)
+	}
+	opts.To = to
+	if !opts.IsDir && path.Clean(to) == "." {
+		return fmt.Errorf("%s: %w", strings.Join(paths, "/"), ErrHomeDirDestination)
+	}
+
+	for _, p := range paths {
+		if glob.HasMeta(p) {
+			return f.expandWildcard(strings.Join(paths, "/"), opts)
+		}
+	}
+
+	entry := Entry{
+		DotfilePath:  strings.Join(paths, "/"),
+		HomeFilePath: opts.To,
+		IndexKey:     strings.Join(paths, "/"),
+	}
+	if !opts.IsDir {
+		entry.HomeFilePath = filepath.Dir(opts.To)
+		entry.HomeFileName = filepath.Base(opts.To)
+	}
+	f.result = append(f.result, entry)
+	return nil
+}
+
+// captureRef matches the {n} references to wildcard captures in destinations.
+var captureRef = regexp.MustCompile(`\{(\d+)\}`)
+
+// expandWildcard adds an entry for every dotfile matching pattern, except
+// those ignored by .flexdotignore or the exclude option. The destination is a
+// directory unless it refers to captures, as in `{to: .config/{1}.toml}`,
+// since several files can match.
+func (f *flattener) expandWildcard(pattern string, opts leafOptions) error {
+	compiled, err := glob.Compile(pattern)
+	if err != nil {
+		return err
+	}
+	exclude, err := ignore.New(opts.Exclude)
+	if err != nil {
+		return fmt.Errorf("%s: invalid exclude: %w", pattern, err)
+	}
+	refs := captureRef.FindAllStringSubmatch(opts.To, -1)
+	for _, ref := range refs {
+		n, _ := strconv.Atoi(ref[1])
+		if n < 1 || n > compiled.NumCaptures() {
+			return fmt.Errorf("%s: destination %q refers to %s, but the pattern has %d wildcard(s)", pattern, opts.To, ref[0], compiled.NumCaptures())
+		}
+	}
+	matches, err := compiled.Expand(f.fs, f.dotfilesDir, f.inBackupDir)
+	if err != nil {
+		return fmt.Errorf("%s: %w", pattern, err)
+	}
+
+	found := false
+
+	for _, match := range matches {
+		rel := strings.TrimPrefix(match, compiled.Base()+"/")
+		isDir := f.isDir(match)
+		if f.ignore.Match(match, isDir) || exclude.Match(rel, isDir) {
+			continue
+		}
+
+		to := opts.To
+		if len(refs) > 0 {
+			captures := compiled.Captures(match)
+			to = captureRef.ReplaceAllStringFunc(to, func(ref string) string {
+				n, _ := strconv.Atoi(ref[1 : len(ref)-1])
+				return captures[n-1]
+			})
+		}
+
+		if len(refs) > 0 && !opts.IsDir && path.Clean(to) == "." {
+			return fmt.Errorf("%s: %s: %w", pattern, match, ErrHomeDirDestination)
+		}
+		entry := Entry{DotfilePath: match, HomeFilePath: to, IndexKey: pattern}
+		if len(refs) > 0 && !opts.IsDir {
+			entry.HomeFilePath = path.Dir(to)
+			entry.HomeFileName = path.Base(to)
+		} else if opts.PreserveDirs {
+			if dir := path.Dir(rel); dir != "." {
+				entry.HomeFilePath = path.Join(entry.HomeFilePath, dir)
+			}
+		}
+		f.result = append(f.result, entry)
+		found = true
+	}
+	if !found {
+		f.noMatches = append(f.noMatches, fmt.Errorf("%w: %s", ErrNoMatches, pattern))
+	}
+	return nil
+}
+
+// inBackupDir reports whether the slash-separated path rel, relative to the
+// dotfiles dir, is in the backup dir.
+func (f *flattener) inBackupDir(rel string) bool {
+	return f.backupDir != "" && (rel == f.backupDir || strings.HasPrefix(rel, f.backupDir+"/"))
+}
+
+func (f *flattener) isDir(rel string) bool {
+	fi, err := f.fs.Lstat(filepath.Join(f.dotfilesDir, filepath.FromSlash(rel)))
+	return err == nil && fi.IsDir()
+}
@@ -1,12 +1,15 @@
 package install
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/hidakatsuya/flexdot-go/internal/backup"
+	"github.com/hidakatsuya/flexdot-go/internal/fsys"
 	"gopkg.in/yaml.v3"
 )
 
@@ -16,186 +19,443 @@ const (
 	AlreadyLinked StatusResult = iota
 	LinkUpdated
 	LinkCreated
+	MissingSource
+	Skipped
 )
 
 type Status struct {
 	Result   StatusResult
 	Backuped bool
+	Adopted  bool // the home file replaced the dotfile
 }
 
 type Entry struct {
 	DotfilePath  string
 	HomeFilePath string
+	HomeFileName string // name of the link, defaults to the dotfile name
+	IndexFile    string // index file the entry comes from
+	IndexKey     string // key of the index, the wildcard for expanded entries
 }
 
-func Install(indexFile, homeDir, dotfilesDir string, keepMaxBackupCount int) error {
-	f, err := os.Open(indexFile)
+// Action is what Apply will do for a planned Step.
+type Action int
+
+const (
+	ActionNone          Action = iota // already linked
+	ActionCreate                      // nothing at the home path, create the link
+	ActionUpdate                      // a symlink points elsewhere in the dotfiles dir, relink it
+	ActionReplace                     // a regular file is in the way, back it up and link
+	ActionReplaceLink                 // a symlink points outside the dotfiles dir, back it up and relink
+	ActionMissingSource               // the dotfile does not exist, nothing is linked
+)
+
+// Step is a single planned change for an index entry.
+type Step struct {
+	Entry    Entry
+	Dotfile  string // absolute path of the dotfile
+	HomeFile string // path of the link in the home directory
+	LinkDest string // current target when HomeFile is a symlink
+	Action   Action
+
+	sourceErr error
+}
+
+// Reporter is called once for every applied Step.
+type Reporter func(step Step, status *Status)
+
+// Resolution is how a regular file in the way of a link is dealt with.
+type Resolution int
+
+const (
+	ResolveBackup Resolution = iota // back up the home file and link the dotfile
+	ResolveAdopt                    // move the home file over the dotfile and link it
+	ResolveSkip                     // leave the home file alone
+)
+
+// Resolver decides the Resolution of a Step with ActionReplace.
+type Resolver func(step Step) (Resolution, error)
+
+// Adopt is a Resolver adopting every home file, as GNU Stow's --adopt does,
+// so that local edits show up as changes in the dotfiles repository.
+func Adopt(Step) (Resolution, error) {
+	return ResolveAdopt, nil
+}
+
+type Installer struct {
+	opts   Options
+	fs     fsys.FS
+	backup *backup.Store
+}
+
+func New(opts Options) *Installer {
+	fs := fsys.Or(opts.FS)
+	if opts.BackupDir == "" {
+		opts.BackupDir = backup.DefaultDir(opts.DotfilesDir)
+	}
+	return &Installer{opts: opts, fs: fs, backup: backup.NewStore(fs, opts.BackupDir)}
+}
+
+// Install plans and applies every entry of the index file, one entry at a
+// time so that later entries see the links created by earlier ones.
+func (in *Installer) Install() error {
+	entries, err := in.Entries()
 	if err != nil {
-		return fmt.Errorf("failed to open index file: %w", err)
+		return err
+	}
+
+	var installErr InstallError
+	in.backup.BeginRun()
+	for _, entry := range entries {
+		step, ok, err := in.planEntry(entry)
+		if err == nil && ok {
+			err = in.applyStep(step)
+		}
+		if err != nil {
+			installErr.Errors = append(installErr.Errors, err)
+		}
+	}
+	in.pruneBackups()
+
+	if len(installErr.Errors) > 0 {
+		return &installErr
+	}
+	return nil
+}
+
+// Entries reads the index file and returns its flattened entries.
+func (in *Installer) Entries() ([]Entry, error) {
+	data, err := in.fs.ReadFile(in.opts.IndexFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open index file: %w", err)
 	}
-	defer f.Close()
 
 	var idxMap map[string]any
-	if err := yaml.NewDecoder(f).Decode(&idxMap); err != nil {
-		return fmt.Errorf("failed to decode index yaml: %w", err)
+	if err := yaml.Unmarshal(data, &idxMap); err != nil {
+		return nil, fmt.Errorf("failed to decode index yaml: %w", err)
+	}
+	entries, noMatches, err := flattenIndex(in.fs, idxMap, in.opts.DotfilesDir, in.opts.BackupDir, in.opts.Vars)
+	if err != nil {
+		return nil, fmt.Errorf("invalid index file: %w", err)
+	}
+	for i := range entries {
+		entries[i].IndexFile = in.opts.IndexFile
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].DotfilePath < entries[j].DotfilePath
+	})
+	if err := in.checkDuplicates(entries); err != nil {
+		return nil, fmt.Errorf("invalid index file: %w", err)
 	}
+	if in.opts.Strict && len(noMatches) > 0 {
+		return nil, errors.Join(noMatches...)
+	}
+	if in.opts.Warn != nil {
+		for _, err := range noMatches {
+			in.opts.Warn(err)
+		}
+	}
+	return entries, nil
+}
 
-	errs := 0
-	for _, entry := range flattenIndex(idxMap, dotfilesDir) {
-		if err := installLink(entry, dotfilesDir, homeDir, keepMaxBackupCount); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			errs++
+// checkDuplicates returns an ErrDuplicateDestination for each entry linking
+// the same path as an earlier one. Entries whose paths cannot be resolved
+// are left to Plan to report.
+func (in *Installer) checkDuplicates(entries []Entry) error {
+	seen := map[string]Entry{}
+	var errs []error
+	for _, entry := range entries {
+		_, homeFile, err := in.Paths(entry)
+		if err != nil {
+			continue
+		}
+		if prev, ok := seen[homeFile]; ok {
+			errs = append(errs, fmt.Errorf("%w: %s and %s both link %s", ErrDuplicateDestination, prev.DotfilePath, entry.DotfilePath, homeFile))
+			continue
+		}
+		seen[homeFile] = entry
+	}
+	return errors.Join(errs...)
+}
+
+// Plan inspects the home directory and decides the action for each entry
+// without changing anything. Entries that cannot be planned are returned
+// in the InstallError and left out of the steps.
+func (in *Installer) Plan(entries []Entry) ([]Step, *InstallError) {
+	var steps []Step
+	var installErr InstallError
+	for _, entry := range entries {
+		step, ok, err := in.planEntry(entry)
+		if err != nil {
+			installErr.Errors = append(installErr.Errors, err)
+			continue
 		}
+		if ok {
+			steps = append(steps, step)
+		}
+	}
+	if len(installErr.Errors) > 0 {
+		return steps, &installErr
 	}
+	return steps, nil
+}
 
-	if errs > 0 {
-		return fmt.Errorf("encountered %d errors during install", errs)
+// Apply performs the planned steps and reports each result.
+func (in *Installer) Apply(steps []Step) *InstallError {
+	var installErr InstallError
+	in.backup.BeginRun()
+	for _, step := range steps {
+		if err := in.applyStep(step); err != nil {
+			installErr.Errors = append(installErr.Errors, err)
+		}
+	}
+	in.pruneBackups()
+	if len(installErr.Errors) > 0 {
+		return &installErr
 	}
 	return nil
 }
 
-func installLink(entry Entry, dotfilesDir, homeDir string, keepMaxBackupCount int) error {
-	dotfile := filepath.Join(dotfilesDir, entry.DotfilePath)
-	homeFile := filepath.Join(homeDir, entry.HomeFilePath, filepath.Base(dotfile))
+// Paths returns the absolute path of the dotfile of entry and the path of
+// its link in the home directory, checking that both stay where they belong.
+func (in *Installer) Paths(entry Entry) (string, string, *EntryError) {
+	dotfile := filepath.Join(in.opts.DotfilesDir, entry.DotfilePath)
+	name := in.linkName(entry, dotfile)
+	homeFile := filepath.Join(in.opts.HomeDir, entry.HomeFilePath, name)
+	if filepath.IsAbs(entry.HomeFilePath) {
+		// An absolute destination, e.g. from $XDG_CONFIG_HOME, is fine as
+		// long as it is inside the home directory.
+		homeFile = filepath.Join(entry.HomeFilePath, name)
+		if homeDir, err := filepath.Abs(in.opts.HomeDir); !in.opts.AllowOutsideHome && (err != nil || !isWithin(homeDir, homeFile)) {
+			return "", "", entryError(entry, "confine", ErrAbsoluteHomeFile)
+		}
+	}
 
 	dotfileAbs, err := filepath.Abs(dotfile)
 	if err != nil {
-		return err
+		return "", "", entryError(entry, "resolve", err)
+	}
+	homeDir, err := filepath.Abs(in.opts.HomeDir)
+	if err != nil {
+		return "", "", entryError(entry, "resolve", err)
+	}
+	if homeFileAbs, err := filepath.Abs(homeFile); err == nil && homeFileAbs == homeDir {
+		return "", "", entryError(entry, "confine", ErrHomeDirDestination)
+	}
+
+	if err := in.checkConfinement(dotfileAbs, homeFile); err != nil {
+		return "", "", entryError(entry, "confine", err)
+	}
+	return dotfileAbs, homeFile, nil
+}
+
+func (in *Installer) planEntry(entry Entry) (Step, bool, *EntryError) {
+	dotfileAbs, homeFile, entryErr := in.Paths(entry)
+	if entryErr != nil {
+		return Step{}, false, entryErr
+	}
+
+	step := Step{Entry: entry, Dotfile: dotfileAbs, HomeFile: homeFile}
+
+	fi, err := in.fs.Lstat(homeFile)
+	isSymlink := err == nil && fi.Mode()&os.ModeSymlink != 0
+	var readlinkErr error
+	if isSymlink {
+		step.LinkDest, readlinkErr = in.fs.Readlink(homeFile)
+	}
+
+	if _, serr := in.fs.Stat(dotfileAbs); serr != nil {
+		if !os.IsNotExist(serr) {
+			return Step{}, false, entryError(entry, "source", serr)
+		}
+		step.Action = ActionMissingSource
+		step.sourceErr = serr
+		return step, true, nil
 	}
 
-	fi, err := os.Lstat(homeFile)
 	switch {
-	case err == nil && fi.Mode()&os.ModeSymlink != 0:
-		return handleSymlink(homeFile, dotfileAbs, homeDir)
+	case isSymlink:
+		switch {
+		case readlinkErr == nil && step.LinkDest == dotfileAbs:
+			step.Action = ActionNone
+		case readlinkErr == nil && !in.isInDotfilesDir(homeFile, step.LinkDest):
+			step.Action = ActionReplaceLink
+		default:
+			step.Action = ActionUpdate
+		}
 	case err == nil && fi.Mode().IsRegular():
-		return handleRegularFile(homeFile, dotfileAbs, homeDir, keepMaxBackupCount)
+		step.Action = ActionReplace
 	case os.IsNotExist(err):
-		return handleNotExist(homeFile, dotfileAbs, homeDir)
+		step.Action = ActionCreate
+	case err != nil:
+		return Step{}, false, entryError(entry, "lstat", err)
 	default:
-		return err
+		return Step{}, false, entryError(entry, "conflict", fmt.Errorf("%w: %s", ErrNotReplaceable, homeFile))
 	}
+	return step, true, nil
 }
 
-func handleSymlink(homeFile, dotfileAbs, homeDir string) error {
-	status := &Status{}
-	linkDest, err := os.Readlink(homeFile)
-	if err == nil && linkDest == dotfileAbs {
-		status.Result = AlreadyLinked
-		OutputLog(homeDir, homeFile, status)
+// linkName returns the name of the link for entry. Without an explicit
+// name it is the dotfile name, with a "dot_" prefix turned into a leading dot
+// when Options.DotPrefix is set.
+func (in *Installer) linkName(entry Entry, dotfile string) string {
+	if entry.HomeFileName != "" {
+		return entry.HomeFileName
+	}
+	name := filepath.Base(dotfile)
+	if in.opts.DotPrefix {
+		if rest, ok := strings.CutPrefix(name, "dot_"); ok && rest != "" {
+			name = "." + rest
+		}
+	}
+	return name
+}
+
+func (in *Installer) applyStep(step Step) *EntryError {
+	switch step.Action {
+	case ActionNone:
+		in.report(step, &Status{Result: AlreadyLinked})
 		return nil
+	case ActionUpdate:
+		return in.handleSymlink(step)
+	case ActionReplace:
+		return in.handleRegularFile(step)
+	case ActionReplaceLink:
+		return in.handleForeignSymlink(step)
+	case ActionMissingSource:
+		in.report(step, &Status{Result: MissingSource})
+		return entryError(step.Entry, "source", step.sourceErr)
+	default:
+		return in.handleNotExist(step)
 	}
+}
+
+func (in *Installer) handleSymlink(step Step) *EntryError {
+	status := &Status{}
 	// Remove old symlink and relink
-	os.Remove(homeFile)
-	if err := os.Symlink(dotfileAbs, homeFile); err != nil {
-		return err
+	in.fs.Remove(step.HomeFile)
+	if err := in.fs.Symlink(step.Dotfile, step.HomeFile); err != nil {
+		return entryError(step.Entry, "symlink", err)
 	}
 	status.Result = LinkUpdated
-	OutputLog(homeDir, homeFile, status)
+	in.report(step, status)
 	return nil
 }
 
-func handleRegularFile(homeFile, dotfileAbs, homeDir string, keepMaxBackupCount int) error {
-	status := &Status{}
-	backupDir, berr := backup.BackupFile(homeFile)
-	if berr != nil {
-		return berr
+func (in *Installer) handleRegularFile(step Step) *EntryError {
+	resolution := ResolveBackup
+	if in.opts.Resolve != nil {
+		var err error
+		if resolution, err = in.opts.Resolve(step); err != nil {
+			return entryError(step.Entry, "resolve", err)
+		}
 	}
-	status.Backuped = true
-	backup.RemoveBackupDirIfEmpty(backupDir)
 
-	if err := os.MkdirAll(filepath.Dir(homeFile), 0755); err != nil {
-		return err
-	}
-	if err := os.Symlink(dotfileAbs, homeFile); err != nil {
-		return err
+	status := &Status{}
+	switch resolution {
+	case ResolveSkip:
+		status.Result = Skipped
+		in.report(step, status)
+		return nil
+	case ResolveAdopt:
+		if err := in.adoptAndLink(step); err != nil {
+			return err
+		}
+		status.Adopted = true
+	default:
+		if err := in.backupAndLink(step); err != nil {
+			return err
+		}
+		status.Backuped = true
 	}
 	status.Result = LinkCreated
+	in.report(step, status)
+	return nil
+}
 
-	if keepMaxBackupCount > 0 {
-		backup.RemoveOutdatedBackups(keepMaxBackupCount)
+// adoptAndLink moves the home file over the dotfile and links it.
+func (in *Installer) adoptAndLink(step Step) *EntryError {
+	fi, err := in.fs.Lstat(step.Dotfile)
+	if err != nil {
+		return entryError(step.Entry, "adopt", err)
+	}
+	if !fi.Mode().IsRegular() {
+		return entryError(step.Entry, "adopt", fmt.Errorf("%s is not a regular file", step.Dotfile))
+	}
+	if err := in.fs.Rename(step.HomeFile, step.Dotfile); err != nil {
+		return entryError(step.Entry, "adopt", err)
+	}
+	if err := in.fs.Symlink(step.Dotfile, step.HomeFile); err != nil {
+		return entryError(step.Entry, "symlink", err)
 	}
-	OutputLog(homeDir, homeFile, status)
 	return nil
 }
 
-func handleNotExist(homeFile, dotfileAbs, homeDir string) error {
+// handleForeignSymlink backs up a symlink that was not created by flexdot,
+// so its target is preserved in the snapshot, and relinks it.
+func (in *Installer) handleForeignSymlink(step Step) *EntryError {
 	status := &Status{}
-	if err := os.MkdirAll(filepath.Dir(homeFile), 0755); err != nil {
+	if err := in.backupAndLink(step); err != nil {
 		return err
 	}
-	if err := os.Symlink(dotfileAbs, homeFile); err != nil {
-		return err
-	}
-	status.Result = LinkCreated
-	OutputLog(homeDir, homeFile, status)
+	status.Backuped = true
+	status.Result = LinkUpdated
+	in.report(step, status)
 	return nil
 }
 
-// FlattenIndex traverses the index map and returns a slice of dotfile/homefile path pairs.
-func flattenIndex(idx map[string]any, dotfilesDir string) []Entry {
-	var result []Entry
-	for root, descendants := range idx {
-		flattenDescendants(descendants, []string{root}, dotfilesDir, &result)
+func (in *Installer) backupAndLink(step Step) *EntryError {
+	backupDir, berr := in.backup.BackupFile(in.opts.HomeDir, step.HomeFile)
+	if berr != nil {
+		return entryError(step.Entry, "backup", berr)
 	}
-	return result
-}
+	in.backup.RemoveBackupDirIfEmpty(backupDir)
 
-func flattenDescendants(descendants any, paths []string, dotfilesDir string, result *[]Entry) {
-	switch v := descendants.(type) {
-	case map[string]any:
-		for k, val := range v {
-			newPaths := append(paths, k)
-			flattenDescendants(val, newPaths, dotfilesDir, result)
-		}
-	case string:
-		hasWildcard := false
-		wildcardIndex := -1
-
-		for i, p := range paths {
-			if strings.Contains(p, "*") {
-				hasWildcard = true
-				wildcardIndex = i
-				break
-			}
-		}
-
-		if hasWildcard {
-			expandWildcard(paths, wildcardIndex, v, dotfilesDir, result)
-		} else {
-			*result = append(*result, Entry{
-				DotfilePath:  strings.Join(paths, "/"),
-				HomeFilePath: v,
-			})
-		}
+	if err := in.fs.MkdirAll(filepath.Dir(step.HomeFile), 0755); err != nil {
+		return entryError(step.Entry, "mkdir", err)
 	}
+	if err := in.fs.Symlink(step.Dotfile, step.HomeFile); err != nil {
+		return entryError(step.Entry, "symlink", err)
+	}
+	return nil
 }
 
-func expandWildcard(paths []string, wildcardIndex int, homeFilePath string, dotfilesDir string, result *[]Entry) {
-	patternPath := strings.Join(paths[:wildcardIndex+1], "/")
-
-	fullPattern := filepath.Join(dotfilesDir, patternPath)
-
-	matches, err := filepath.Glob(fullPattern)
-	if err != nil || len(matches) == 0 {
-		return
+// pruneBackups removes the outdated snapshots once a run has backed up
+// something, so that the run's own snapshot is counted as the newest.
+func (in *Installer) pruneBackups() {
+	if in.backup.Snapshot() != "" && in.opts.KeepMaxBackupCount > 0 {
+		in.backup.RemoveOutdatedBackups(in.opts.KeepMaxBackupCount)
 	}
+}
 
-	for _, match := range matches {
-		relPath, err := filepath.Rel(dotfilesDir, match)
-		if err != nil {
-			continue
-		}
-
-		matchPath := filepath.ToSlash(relPath)
+// isInDotfilesDir reports whether linkDest, the target of the symlink at
+// homeFile, points into the dotfiles dir.
+func (in *Installer) isInDotfilesDir(homeFile, linkDest string) bool {
+	if !filepath.IsAbs(linkDest) {
+		linkDest = filepath.Join(filepath.Dir(homeFile), linkDest)
+	}
+	dotfilesDir, err := filepath.Abs(in.opts.DotfilesDir)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(dotfilesDir, filepath.Clean(linkDest))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
 
-		if wildcardIndex < len(paths)-1 {
-			remainingPaths := paths[wildcardIndex+1:]
-			matchPath = matchPath + "/" + strings.Join(remainingPaths, "/")
-		}
+func (in *Installer) handleNotExist(step Step) *EntryError {
+	status := &Status{}
+	if err := in.fs.MkdirAll(filepath.Dir(step.HomeFile), 0755); err != nil {
+		return entryError(step.Entry, "mkdir", err)
+	}
+	if err := in.fs.Symlink(step.Dotfile, step.HomeFile); err != nil {
+		return entryError(step.Entry, "symlink", err)
+	}
+	status.Result = LinkCreated
+	in.report(step, status)
+	return nil
+}
 
-		*result = append(*result, Entry{
-			DotfilePath:  matchPath,
-			HomeFilePath: homeFilePath,
-		})
+func (in *Installer) report(step Step, status *Status) {
+	if in.opts.Reporter != nil {
+		in.opts.Reporter(step, status)
 	}
 }
@@ -0,0 +1,574 @@
+package install
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hidakatsuya/flexdot-go/internal/fsys"
+)
+
+func newTestInstaller(t *testing.T, index string) (*Installer, *fsys.MemFS) {
+	t.Helper()
+	mem := fsys.NewMemFS()
+	if err := mem.WriteFile("/dotfiles/index.yml", []byte(index), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := mem.MkdirAll("/home", 0755); err != nil {
+		t.Fatal(err)
+	}
+	return New(Options{
+		IndexFile:   "/dotfiles/index.yml",
+		HomeDir:     "/home",
+		DotfilesDir: "/dotfiles",
+		FS:          mem,
+	}), mem
+}
+
+func TestInstallerCreatesLinks(t *testing.T) {
+	in, mem := newTestInstaller(t, "vim:\n  .vimrc: .\nbin:\n  myscript: bin\n")
+	mem.WriteFile("/dotfiles/vim/.vimrc", []byte("set nu"), 0644)
+	mem.WriteFile("/dotfiles/bin/myscript", []byte("#!/bin/sh"), 0755)
+
+	if err := in.Install(); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+
+	for link, want := range map[string]string{
+		"/home/.vimrc":       "/dotfiles/vim/.vimrc",
+		"/home/bin/myscript": "/dotfiles/bin/myscript",
+	} {
+		dest, err := mem.Readlink(link)
+		if err != nil {
+			t.Errorf("symlink %s not created: %v", link, err)
+			continue
+		}
+		if dest != want {
+			t.Errorf("symlink %s points to %s, want %s", link, dest, want)
+		}
+	}
+}
+
+func TestInstallerPlanActions(t *testing.T) {
+	in, mem := newTestInstaller(t, "a: .\nb: .\nc: .\nd: .\ne: .\n")
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		mem.WriteFile("/dotfiles/"+name, []byte(name), 0644)
+	}
+	mem.Symlink("/dotfiles/a", "/home/a")
+	mem.Symlink("/dotfiles/old/b", "/home/b")
+	mem.WriteFile("/home/c", []byte("local"), 0644)
+	mem.Symlink("/opt/corp/e", "/home/e")
+
+	entries, err := in.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps, planErr := in.Plan(entries)
+	if planErr != nil {
+		t.Fatalf("Plan failed: %v", planErr)
+	}
+
+	want := map[string]Action{
+		"/home/a": ActionNone,
+		"/home/b": ActionUpdate,
+		"/home/c": ActionReplace,
+		"/home/d": ActionCreate,
+		"/home/e": ActionReplaceLink,
+	}
+	if len(steps) != len(want) {
+		t.Fatalf("got %d steps, want %d", len(steps), len(want))
+	}
+	for _, step := range steps {
+		if step.Action != want[step.HomeFile] {
+			t.Errorf("%s: action %d, want %d", step.HomeFile, step.Action, want[step.HomeFile])
+		}
+	}
+}
+
+func TestInstallerBacksUpRegularFile(t *testing.T) {
+	in, mem := newTestInstaller(t, "myfile.txt: .\n")
+	mem.WriteFile("/dotfiles/myfile.txt", []byte("hello"), 0644)
+	mem.WriteFile("/home/myfile.txt", []byte("old content"), 0644)
+
+	var backuped bool
+	in.opts.Reporter = func(step Step, status *Status) {
+		backuped = status.Backuped
+	}
+	if err := in.Install(); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	if !backuped {
+		t.Error("expected the home file to be reported as backed up")
+	}
+
+	fi, err := mem.Lstat("/home/myfile.txt")
+	if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("expected /home/myfile.txt to be a symlink: %v", err)
+	}
+	snapshots, err := mem.ReadDir("/dotfiles/backup")
+	if err != nil || len(snapshots) != 1 {
+		t.Fatalf("expected one backup snapshot: %v", err)
+	}
+	data, err := mem.ReadFile("/dotfiles/backup/" + snapshots[0].Name() + "/myfile.txt")
+	if err != nil {
+		t.Fatalf("backup file not found: %v", err)
+	}
+	if string(data) != "old content" {
+		t.Errorf("backup content is %q, want %q", data, "old content")
+	}
+}
+
+func TestInstallerBacksUpIntoOneSnapshot(t *testing.T) {
+	in, mem := newTestInstaller(t, "a: .\nb: .\n")
+	in.opts.KeepMaxBackupCount = 1
+	for _, name := range []string{"a", "b"} {
+		mem.WriteFile("/dotfiles/"+name, []byte(name), 0644)
+		mem.WriteFile("/home/"+name, []byte("old "+name), 0644)
+	}
+	mem.WriteFile("/dotfiles/backup/20000101000000/x", []byte("x"), 0644)
+
+	if err := in.Install(); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+
+	snapshots, err := mem.ReadDir("/dotfiles/backup")
+	if err != nil || len(snapshots) != 1 || snapshots[0].Name() == "20000101000000" {
+		t.Fatalf("expected only the snapshot of this install: %v %v", snapshots, err)
+	}
+	for _, name := range []string{"a", "b"} {
+		if _, err := mem.ReadFile("/dotfiles/backup/" + snapshots[0].Name() + "/" + name); err != nil {
+			t.Errorf("backup of %s not found: %v", name, err)
+		}
+	}
+}
+
+func TestInstallerAdoptsRegularFile(t *testing.T) {
+	in, mem := newTestInstaller(t, "myfile.txt: .\n")
+	mem.WriteFile("/dotfiles/myfile.txt", []byte("hello"), 0644)
+	mem.WriteFile("/home/myfile.txt", []byte("local edit"), 0644)
+
+	var status Status
+	in.opts.Resolve = Adopt
+	in.opts.Reporter = func(step Step, s *Status) {
+		status = *s
+	}
+	if err := in.Install(); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	if !status.Adopted || status.Backuped || status.Result != LinkCreated {
+		t.Errorf("expected the home file to be reported as adopted, got %+v", status)
+	}
+
+	if dest, err := mem.Readlink("/home/myfile.txt"); err != nil || dest != "/dotfiles/myfile.txt" {
+		t.Fatalf("expected /home/myfile.txt to link to the dotfile: %q %v", dest, err)
+	}
+	if data, _ := mem.ReadFile("/dotfiles/myfile.txt"); string(data) != "local edit" {
+		t.Errorf("dotfile content is %q, want the adopted %q", data, "local edit")
+	}
+	if _, err := mem.Lstat("/dotfiles/backup"); err == nil {
+		t.Error("adopting should not create a backup")
+	}
+}
+
+func TestInstallerPromptResolver(t *testing.T) {
+	in, mem := newTestInstaller(t, "a: .\nb: .\nc: .\n")
+	for _, name := range []string{"a", "b", "c"} {
+		mem.WriteFile("/dotfiles/"+name, []byte("repo\n"), 0644)
+		mem.WriteFile("/home/"+name, []byte("home\n"), 0644)
+	}
+
+	// An invalid answer is asked again, and the end of input skips
+	var out strings.Builder
+	in.opts.Resolve = PromptResolver(mem, "/home", strings.NewReader("x\nh\nr\n"), &out)
+	results := map[string]Status{}
+	in.opts.Reporter = func(step Step, s *Status) {
+		results[step.HomeFile] = *s
+	}
+	entries, err := in.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps, planErr := in.Plan(entries)
+	if planErr != nil {
+		t.Fatal(planErr)
+	}
+	if err := in.Apply(steps); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "-repo\n+home\n") {
+		t.Errorf("expected the diff in the prompt, got:\n%s", out.String())
+	}
+	// Entries are in no particular order, so count the resolutions
+	var adopted, backuped, skipped int
+	for homeFile, s := range results {
+		switch {
+		case s.Adopted:
+			adopted++
+		case s.Backuped:
+			backuped++
+		case s.Result == Skipped:
+			skipped++
+			if data, _ := mem.ReadFile(homeFile); string(data) != "home\n" {
+				t.Errorf("skipped home file changed to %q", data)
+			}
+		}
+	}
+	if adopted != 1 || backuped != 1 || skipped != 1 {
+		t.Errorf("got %d adopted, %d backed up and %d skipped, want one of each", adopted, backuped, skipped)
+	}
+}
+
+func TestInstallerWildcard(t *testing.T) {
+	in, mem := newTestInstaller(t, "prompts:\n  \"*.md\": .codex/prompts\n")
+	mem.WriteFile("/dotfiles/prompts/code.md", nil, 0644)
+	mem.WriteFile("/dotfiles/prompts/test.md", nil, 0644)
+	mem.WriteFile("/dotfiles/prompts/other.txt", nil, 0644)
+
+	if err := in.Install(); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	for _, name := range []string{"code.md", "test.md"} {
+		if _, err := mem.Readlink("/home/.codex/prompts/" + name); err != nil {
+			t.Errorf("symlink for %s not created: %v", name, err)
+		}
+	}
+	if _, err := mem.Lstat("/home/.codex/prompts/other.txt"); err == nil {
+		t.Error(".txt file should not have been symlinked")
+	}
+}
+
+func TestInstallerBacksUpForeignSymlink(t *testing.T) {
+	in, mem := newTestInstaller(t, "bash:\n  .bashrc: .\n")
+	mem.WriteFile("/dotfiles/bash/.bashrc", []byte("export A=1"), 0644)
+	mem.Symlink("/opt/corp/bashrc", "/home/.bashrc")
+
+	var status Status
+	in.opts.Reporter = func(step Step, s *Status) {
+		status = *s
+	}
+	if err := in.Install(); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	if status.Result != LinkUpdated || !status.Backuped {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	snapshots, err := mem.ReadDir("/dotfiles/backup")
+	if err != nil || len(snapshots) != 1 {
+		t.Fatalf("expected one backup snapshot: %v", err)
+	}
+	dest, err := mem.Readlink("/dotfiles/backup/" + snapshots[0].Name() + "/.bashrc")
+	if err != nil {
+		t.Fatalf("backed up symlink not found: %v", err)
+	}
+	if dest != "/opt/corp/bashrc" {
+		t.Errorf("backed up symlink points to %s, want /opt/corp/bashrc", dest)
+	}
+}
+
+func TestInstallerRejectsEscapingPaths(t *testing.T) {
+	in, mem := newTestInstaller(t, "../../etc/passwd: .\nmyfile.txt: ../..\nother.txt: link\nabs.txt: /etc\n")
+	mem.WriteFile("/etc/passwd", []byte("root"), 0644)
+	mem.WriteFile("/dotfiles/myfile.txt", nil, 0644)
+	mem.WriteFile("/dotfiles/other.txt", nil, 0644)
+	mem.WriteFile("/dotfiles/abs.txt", nil, 0644)
+	mem.Symlink("/etc", "/home/link")
+
+	entries, err := in.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps, planErr := in.Plan(entries)
+	if len(steps) != 0 {
+		t.Errorf("expected no steps, got %+v", steps)
+	}
+	if planErr == nil || len(planErr.Errors) != 4 {
+		t.Fatalf("expected 4 errors, got %v", planErr)
+	}
+	for _, err := range planErr.Errors {
+		if err.Op != "confine" {
+			t.Errorf("%s: op %q, want confine", err.Entry.DotfilePath, err.Op)
+		}
+	}
+}
+
+func TestInstallerAllowOutsideHome(t *testing.T) {
+	in, mem := newTestInstaller(t, "abs.txt: /etc\n")
+	mem.WriteFile("/dotfiles/abs.txt", nil, 0644)
+	mem.MkdirAll("/etc", 0755)
+	in.opts.AllowOutsideHome = true
+
+	if err := in.Install(); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	if dest, err := mem.Readlink("/etc/abs.txt"); err != nil || dest != "/dotfiles/abs.txt" {
+		t.Errorf("expected /etc/abs.txt to link to /dotfiles/abs.txt, got %q (%v)", dest, err)
+	}
+}
+
+func TestInstallerBacksUpOutsideHomeByAbsolutePath(t *testing.T) {
+	in, mem := newTestInstaller(t, "foo:\n  config: /etc/foo\nbar:\n  config: /opt/bar\n")
+	in.opts.AllowOutsideHome = true
+	for _, dir := range []string{"foo", "bar"} {
+		mem.WriteFile("/dotfiles/"+dir+"/config", []byte(dir), 0644)
+	}
+	mem.WriteFile("/etc/foo/config", []byte("old foo"), 0644)
+	mem.WriteFile("/opt/bar/config", []byte("old bar"), 0644)
+
+	if err := in.Install(); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+
+	snapshot := "/dotfiles/backup/" + in.backup.Snapshot()
+	for path, want := range map[string]string{
+		snapshot + "/_abs/etc/foo/config": "old foo",
+		snapshot + "/_abs/opt/bar/config": "old bar",
+	} {
+		if data, err := mem.ReadFile(path); err != nil || string(data) != want {
+			t.Errorf("%s = %q (%v), want %q", path, data, err, want)
+		}
+	}
+}
+
+func TestInstallerExplicitDestination(t *testing.T) {
+	in, mem := newTestInstaller(t, "bash:\n  bashrc: {to: .bashrc}\n  profile:\n    to: .config/bash/\n")
+	mem.WriteFile("/dotfiles/bash/bashrc", nil, 0644)
+	mem.WriteFile("/dotfiles/bash/profile", nil, 0644)
+
+	if err := in.Install(); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	for link, want := range map[string]string{
+		"/home/.bashrc":              "/dotfiles/bash/bashrc",
+		"/home/.config/bash/profile": "/dotfiles/bash/profile",
+	} {
+		if dest, err := mem.Readlink(link); err != nil || dest != want {
+			t.Errorf("expected %s to link to %s, got %q (%v)", link, want, dest, err)
+		}
+	}
+}
+
+func TestInstallerDotPrefix(t *testing.T) {
+	in, mem := newTestInstaller(t, "bash:\n  dot_bashrc: .\n  dot_profile: {to: profile}\n")
+	mem.WriteFile("/dotfiles/bash/dot_bashrc", nil, 0644)
+	mem.WriteFile("/dotfiles/bash/dot_profile", nil, 0644)
+	in.opts.DotPrefix = true
+
+	if err := in.Install(); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	if dest, err := mem.Readlink("/home/.bashrc"); err != nil || dest != "/dotfiles/bash/dot_bashrc" {
+		t.Errorf("expected /home/.bashrc to link to dot_bashrc, got %q (%v)", dest, err)
+	}
+	// An explicit name is used as it is
+	if _, err := mem.Readlink("/home/profile"); err != nil {
+		t.Errorf("expected /home/profile to be linked: %v", err)
+	}
+}
+
+func TestInstallerRecursiveWildcard(t *testing.T) {
+	in, mem := newTestInstaller(t, "notes:\n  \"**/*.md\": {to: docs, preserve_dirs: true}\napps:\n  \"*/config/*.toml\": .config\n")
+	mem.WriteFile("/dotfiles/notes/a.md", nil, 0644)
+	mem.WriteFile("/dotfiles/notes/sub/b.md", nil, 0644)
+	mem.WriteFile("/dotfiles/apps/x/config/x.toml", nil, 0644)
+	mem.WriteFile("/dotfiles/apps/y/config/y.toml", nil, 0644)
+
+	if err := in.Install(); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	for link, want := range map[string]string{
+		"/home/docs/a.md":      "/dotfiles/notes/a.md",
+		"/home/docs/sub/b.md":  "/dotfiles/notes/sub/b.md",
+		"/home/.config/x.toml": "/dotfiles/apps/x/config/x.toml",
+		"/home/.config/y.toml": "/dotfiles/apps/y/config/y.toml",
+	} {
+		if dest, err := mem.Readlink(link); err != nil || dest != want {
+			t.Errorf("expected %s to link to %s, got %q (%v)", link, want, dest, err)
+		}
+	}
+}
+
+func TestInstallerExcludeAndIgnoreFile(t *testing.T) {
+	in, mem := newTestInstaller(t, "prompts:\n  \"*.md\":\n    to: .codex/prompts\n    exclude: [README.md]\n")
+	mem.WriteFile("/dotfiles/.flexdotignore", []byte("# editor files\n*.swp\n.DS_Store\nscratch/\n"), 0644)
+	for _, name := range []string{"code.md", "README.md", "draft.md.swp", ".DS_Store", "scratch/notes.md"} {
+		mem.WriteFile("/dotfiles/prompts/"+name, nil, 0644)
+	}
+
+	entries, err := in.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].DotfilePath != "prompts/code.md" {
+		t.Errorf("expected only prompts/code.md, got %+v", entries)
+	}
+
+	in2, mem2 := newTestInstaller(t, "prompts:\n  \"**\": .\n")
+	mem2.WriteFile("/dotfiles/.flexdotignore", []byte("scratch/\n!keep.swp\n*.swp\n"), 0644)
+	for _, name := range []string{"a.md", "b.swp", "scratch/c.md"} {
+		mem2.WriteFile("/dotfiles/prompts/"+name, nil, 0644)
+	}
+	entries, err = in2.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].DotfilePath != "prompts/a.md" {
+		t.Errorf("expected only prompts/a.md, got %+v", entries)
+	}
+}
+
+func TestInstallerCaptureSubstitution(t *testing.T) {
+	in, mem := newTestInstaller(t, "apps:\n  \"*/config.toml\": .config/{1}\nthemes:\n  \"*.yml\": {to: \".themes/{1}.theme\"}\n")
+	mem.WriteFile("/dotfiles/apps/foo/config.toml", nil, 0644)
+	mem.WriteFile("/dotfiles/apps/bar/config.toml", nil, 0644)
+	mem.WriteFile("/dotfiles/themes/dark.yml", nil, 0644)
+
+	if err := in.Install(); err != nil {
+		t.Fatal(err)
+	}
+	for link, dest := range map[string]string{
+		"/home/.config/foo/config.toml": "/dotfiles/apps/foo/config.toml",
+		"/home/.config/bar/config.toml": "/dotfiles/apps/bar/config.toml",
+		"/home/.themes/dark.theme":      "/dotfiles/themes/dark.yml",
+	} {
+		if got, err := mem.Readlink(link); err != nil || got != dest {
+			t.Errorf("%s: got %q, %v; want %q", link, got, err, dest)
+		}
+	}
+
+	in, _ = newTestInstaller(t, "apps:\n  \"*/config.toml\": .config/{2}\n")
+	if _, err := in.Entries(); err == nil || !strings.Contains(err.Error(), "{2}") {
+		t.Errorf("expected an error for an out-of-range capture, got %v", err)
+	}
+}
+
+func TestInstallerWildcardWithoutMatches(t *testing.T) {
+	const index = "prompts:\n  \"*.md\": .codex/prompts\n  \"*.txt\": {to: .codex/prompts, exclude: \"*.txt\"}\n"
+	in, mem := newTestInstaller(t, index)
+	mem.WriteFile("/dotfiles/prompts/notes.txt", nil, 0644)
+
+	var warnings []error
+	in.opts.Warn = func(err error) { warnings = append(warnings, err) }
+	entries, err := in.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected no entries, got %+v", entries)
+	}
+	if len(warnings) != 2 || !errors.Is(warnings[0], ErrNoMatches) || !strings.Contains(warnings[0].Error(), "prompts/*.md") {
+		t.Errorf("expected ErrNoMatches warnings for both patterns, got %v", warnings)
+	}
+
+	in.opts.Strict = true
+	if _, err := in.Entries(); !errors.Is(err, ErrNoMatches) {
+		t.Errorf("expected ErrNoMatches in strict mode, got %v", err)
+	}
+}
+
+func TestInstallerMalformedWildcard(t *testing.T) {
+	for _, index := range []string{
+		"prompts:\n  \"[a.md\": .\n",
+		"prompts:\n  \"*.md\": {exclude: \"[x\"}\n",
+	} {
+		in, _ := newTestInstaller(t, index)
+		if _, err := in.Entries(); err == nil || !strings.Contains(err.Error(), "invalid") {
+			t.Errorf("%q: expected an invalid pattern error, got %v", index, err)
+		}
+	}
+}
+
+func TestInstallerExpandsDestination(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/home/.config")
+	in, mem := newTestInstaller(t, "init.lua: $XDG_CONFIG_HOME/nvim\nbashrc: {to: ~/.bashrc}\n")
+	mem.WriteFile("/dotfiles/init.lua", nil, 0644)
+	mem.WriteFile("/dotfiles/bashrc", nil, 0644)
+
+	if err := in.Install(); err != nil {
+		t.Fatal(err)
+	}
+	for _, link := range []string{"/home/.config/nvim/init.lua", "/home/.bashrc"} {
+		if _, err := mem.Readlink(link); err != nil {
+			t.Errorf("expected %s to be linked: %v", link, err)
+		}
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", "/etc/xdg")
+	if err := in.Install(); !errors.Is(err, ErrAbsoluteHomeFile) {
+		t.Errorf("expected ErrAbsoluteHomeFile for a destination outside home, got %v", err)
+	}
+}
+
+func TestInstallerWildcardSkipsBackupDir(t *testing.T) {
+	in, mem := newTestInstaller(t, "\"**/*.md\": .notes\n")
+	mem.WriteFile("/dotfiles/notes/a.md", []byte("repo"), 0644)
+	mem.WriteFile("/home/.notes/a.md", []byte("local"), 0644)
+
+	// The first install backs the home file up into the dotfiles dir
+	if err := in.Install(); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	entries, err := in.Entries()
+	if err != nil {
+		t.Fatalf("Entries failed after a backup: %v", err)
+	}
+	if len(entries) != 1 || entries[0].DotfilePath != "notes/a.md" {
+		t.Errorf("expected only notes/a.md, got %+v", entries)
+	}
+	if err := in.Install(); err != nil {
+		t.Fatalf("second Install failed: %v", err)
+	}
+	if dest, err := mem.Readlink("/home/.notes/a.md"); err != nil || dest != "/dotfiles/notes/a.md" {
+		t.Errorf("expected the link to the dotfile, got %q (%v)", dest, err)
+	}
+}
+
+func TestInstallerDuplicateDestination(t *testing.T) {
+	in, mem := newTestInstaller(t, "a:\n  .vimrc: .\nb:\n  .vimrc: .\n")
+	mem.WriteFile("/dotfiles/a/.vimrc", nil, 0644)
+	mem.WriteFile("/dotfiles/b/.vimrc", nil, 0644)
+
+	_, err := in.Entries()
+	if !errors.Is(err, ErrDuplicateDestination) {
+		t.Fatalf("expected ErrDuplicateDestination, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "a/.vimrc and b/.vimrc both link /home/.vimrc") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := in.Install(); !errors.Is(err, ErrDuplicateDestination) {
+		t.Errorf("expected Install to fail with ErrDuplicateDestination, got %v", err)
+	}
+}
+
+func TestInstallerRejectsHomeDirDestination(t *testing.T) {
+	for _, index := range []string{"foo: {to: .}\n", "foo: {to: \"~\"}\n", "foo: {to: /home}\n"} {
+		in, mem := newTestInstaller(t, index)
+		mem.WriteFile("/dotfiles/foo", nil, 0644)
+		in.opts.AllowOutsideHome = true
+
+		err := in.Install()
+		if !errors.Is(err, ErrHomeDirDestination) {
+			t.Errorf("%q: expected ErrHomeDirDestination, got %v", index, err)
+		}
+	}
+}
+
+func TestInstallerReportsDirectoryInTheWay(t *testing.T) {
+	in, mem := newTestInstaller(t, "nvim: .config\n")
+	mem.WriteFile("/dotfiles/nvim/init.lua", nil, 0644)
+	mem.WriteFile("/home/.config/nvim/init.lua", nil, 0644)
+
+	entries, err := in.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps, planErr := in.Plan(entries)
+	if len(steps) != 0 || planErr == nil || !errors.Is(planErr, ErrNotReplaceable) {
+		t.Fatalf("expected ErrNotReplaceable, got steps %+v and %v", steps, planErr)
+	}
+	if fi, err := mem.Lstat("/home/.config/nvim"); err != nil || !fi.IsDir() {
+		t.Errorf("the directory in the way should be left alone: %v", err)
+	}
+}
@@ -2,9 +2,17 @@ package install
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 )
 
+// LogReporter returns a Reporter that prints each result with OutputLog.
+func LogReporter(homeDir string) Reporter {
+	return func(step Step, status *Status) {
+		OutputLog(homeDir, step.HomeFile, status)
+	}
+}
+
 func OutputLog(homeDir, homeFile string, status *Status) {
 	var resultStr string
 	var colorCode string
@@ -19,6 +27,12 @@ func OutputLog(homeDir, homeFile string, status *Status) {
 	case LinkCreated:
 		resultStr = "link created:"
 		colorCode = "\033[32m" // green
+	case MissingSource:
+		resultStr = "missing source:"
+		colorCode = "\033[31m" // red
+	case Skipped:
+		resultStr = "skipped:"
+		colorCode = "\033[33m" // yellow
 	default:
 		resultStr = "result:"
 		colorCode = ""
@@ -41,5 +55,14 @@ func OutputLog(homeDir, homeFile string, status *Status) {
 	if status.Backuped {
 		msg += " (backup)"
 	}
+	if status.Adopted {
+		msg += " (adopted)"
+	}
 	fmt.Println(msg)
 }
+
+// OutputWarning prints a non-fatal problem, such as a wildcard without
+// matches, to stderr.
+func OutputWarning(err error) {
+	fmt.Fprintln(os.Stderr, "\033[33mwarning:\033[0m "+err.Error())
+}
@@ -0,0 +1,63 @@
+package install
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/hidakatsuya/flexdot-go/internal/diff"
+	"github.com/hidakatsuya/flexdot-go/internal/fsys"
+)
+
+// PromptResolver returns a Resolver that shows the diff between each
+// dotfile and the home file in its way on out, and asks on in whether to
+// keep the home file (adopting it), keep the repo file (backing up the home
+// file) or skip the entry. The entry is skipped at the end of in.
+func PromptResolver(fs fsys.FS, homeDir string, in io.Reader, out io.Writer) Resolver {
+	fs = fsys.Or(fs)
+	reader := bufio.NewReader(in)
+	return func(step Step) (Resolution, error) {
+		relPath, err := filepath.Rel(homeDir, step.HomeFile)
+		if err != nil {
+			relPath = step.HomeFile
+		}
+		fmt.Fprintf(out, "conflict: %s\n", relPath)
+		fmt.Fprint(out, conflictDiff(fs, step, relPath))
+
+		for {
+			fmt.Fprint(out, "Keep [h]ome, keep [r]epo or [s]kip? ")
+			answer, err := reader.ReadString('\n')
+			switch strings.ToLower(strings.TrimSpace(answer)) {
+			case "h", "home":
+				return ResolveAdopt, nil
+			case "r", "repo":
+				return ResolveBackup, nil
+			case "s", "skip":
+				return ResolveSkip, nil
+			}
+			if err != nil {
+				fmt.Fprintln(out)
+				return ResolveSkip, nil
+			}
+		}
+	}
+}
+
+// conflictDiff returns the unified diff from the dotfile of step to the home
+// file, or a note when they cannot be compared line by line.
+func conflictDiff(fs fsys.FS, step Step, relPath string) string {
+	repo, err := fs.ReadFile(step.Dotfile)
+	if err != nil {
+		return fmt.Sprintf("cannot compare: %v\n", err)
+	}
+	home, err := fs.ReadFile(step.HomeFile)
+	if err != nil {
+		return fmt.Sprintf("cannot compare: %v\n", err)
+	}
+	if d := diff.Files(step.Entry.DotfilePath, relPath, repo, home); d != "" {
+		return d
+	}
+	return "files are identical\n"
+}
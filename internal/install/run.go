@@ -2,15 +2,51 @@ package install
 
 import (
 	"fmt"
+
+	"github.com/hidakatsuya/flexdot-go/internal/fsys"
 )
 
 type Options struct {
+	IndexFile          string
+	HomeDir            string
 	DotfilesDir        string
-	KeepMaxBackupCount any // nil or int
+	BackupDir          string // defaults to <DotfilesDir>/backup
+	KeepMaxBackupCount int
+	Reporter           Reporter // nil disables reporting
+	FS                 fsys.FS  // nil means the real filesystem
+
+	// AllowOutsideHome permits absolute destinations and destinations that
+	// resolve outside HomeDir, for intentionally managed system files.
+	AllowOutsideHome bool
+
+	// DotPrefix links dotfiles named "dot_foo" as ".foo".
+	DotPrefix bool
+
+	// Vars are looked up before the environment when expanding $VAR in
+	// destinations.
+	Vars map[string]string
+
+	// Resolve decides what to do with each regular file in the way of a
+	// link; nil backs them all up.
+	Resolve Resolver
+
+	// Warn is called for each wildcard that matches no files; nil ignores
+	// them. With Strict they fail Entries instead.
+	Warn   func(err error)
+	Strict bool
 }
 
-func Run(indexFile, homeDir, dotfilesDir string, keepMaxBackupCount int) error {
-	if err := Install(indexFile, homeDir, dotfilesDir, keepMaxBackupCount); err != nil {
+// Run installs with opts, printing each result with OutputLog unless
+// opts.Reporter is set, and warnings with OutputWarning unless opts.Warn is.
+func Run(opts Options) error {
+	if opts.Reporter == nil {
+		opts.Reporter = LogReporter(opts.HomeDir)
+	}
+	if opts.Warn == nil {
+		opts.Warn = OutputWarning
+	}
+	installer := New(opts)
+	if err := installer.Install(); err != nil {
 		return fmt.Errorf("install failed: %w", err)
 	}
 	return nil
@@ -0,0 +1,133 @@
+package listcmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hidakatsuya/flexdot-go/internal/fsys"
+	"github.com/hidakatsuya/flexdot-go/internal/install"
+)
+
+// ErrNotManaged is returned by Which for a path no entry links.
+var ErrNotManaged = errors.New("not managed by the index")
+
+// Item is an entry of the index resolved to absolute paths, as printed by
+// List with JSON.
+type Item struct {
+	Source      string `json:"source"`      // the dotfile
+	Destination string `json:"destination"` // the link in the home directory
+	Index       string `json:"index"`       // the index file
+	Key         string `json:"key"`         // the key of the index
+}
+
+// List prints every entry of the index file, after expanding wildcards,
+// with its source, destination and index file. With asJSON it prints them
+// as a JSON array of Item.
+func List(w io.Writer, opts install.Options, asJSON bool) error {
+	items, err := resolve(opts)
+	if err != nil {
+		return err
+	}
+
+	homeDir, err := filepath.Abs(opts.HomeDir)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		if items == nil {
+			items = []Item{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(items)
+	}
+	for _, item := range items {
+		fmt.Fprintf(w, "%s -> %s (%s)\n", rel(opts.DotfilesDir, item.Source), rel(homeDir, item.Destination), rel(opts.DotfilesDir, item.Index))
+	}
+	return nil
+}
+
+// Which prints the entry linking path, a file in the home directory or
+// inside a linked directory, and the dotfile it resolves to.
+func Which(w io.Writer, opts install.Options, path string) error {
+	target, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	items, err := resolve(opts)
+	if err != nil {
+		return err
+	}
+
+	fs := fsys.Or(opts.FS)
+	for _, item := range items {
+		source := ""
+		if target == item.Destination {
+			source = item.Source
+		} else if rest, ok := strings.CutPrefix(target, item.Destination+string(filepath.Separator)); ok {
+			// A file inside a linked directory
+			if fi, err := fs.Stat(item.Source); err != nil || !fi.IsDir() {
+				continue
+			}
+			source = filepath.Join(item.Source, rest)
+		} else {
+			continue
+		}
+
+		fmt.Fprintf(w, "source: %s\n", rel(opts.DotfilesDir, source))
+		fmt.Fprintf(w, "entry: %s\n", item.Key)
+		fmt.Fprintf(w, "index: %s\n", rel(opts.DotfilesDir, item.Index))
+		return nil
+	}
+	return fmt.Errorf("%s: %w", path, ErrNotManaged)
+}
+
+// resolve returns the entries of the index file sorted by source.
+func resolve(opts install.Options) ([]Item, error) {
+	if opts.Warn == nil {
+		opts.Warn = install.OutputWarning
+	}
+	installer := install.New(opts)
+	entries, err := installer.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	var items []Item
+	var installErr install.InstallError
+	for _, entry := range entries {
+		dotfile, homeFile, entryErr := installer.Paths(entry)
+		if entryErr != nil {
+			installErr.Errors = append(installErr.Errors, entryErr)
+			continue
+		}
+		if homeFile, err = filepath.Abs(homeFile); err != nil {
+			return nil, err
+		}
+		items = append(items, Item{Source: dotfile, Destination: homeFile, Index: entry.IndexFile, Key: entry.IndexKey})
+	}
+	if len(installErr.Errors) > 0 {
+		return nil, &installErr
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Source != items[j].Source {
+			return items[i].Source < items[j].Source
+		}
+		return items[i].Destination < items[j].Destination
+	})
+	return items, nil
+}
+
+// rel returns path relative to base when it is inside it.
+func rel(base, path string) string {
+	if r, err := filepath.Rel(base, path); err == nil && r != ".." && !strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+		return r
+	}
+	return path
+}
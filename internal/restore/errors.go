@@ -0,0 +1,41 @@
+package restore
+
+import (
+	"fmt"
+)
+
+// FileError describes a failure while restoring a single backed up file.
+type FileError struct {
+	Path string // where the file is restored to
+	Op   string
+	Err  error // usually *os.PathError or *os.LinkError
+}
+
+func (e *FileError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Op, e.Path, e.Err)
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// RestoreError collects every FileError encountered during a restore.
+type RestoreError struct {
+	Errors []*FileError
+}
+
+func (e *RestoreError) Error() string {
+	if len(e.Errors) == 1 {
+		return "encountered 1 error during restore"
+	}
+	return fmt.Sprintf("encountered %d errors during restore", len(e.Errors))
+}
+
+// Unwrap allows errors.Is and errors.As to inspect each file error.
+func (e *RestoreError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
@@ -0,0 +1,105 @@
+package restore
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/hidakatsuya/flexdot-go/internal/backup"
+	"github.com/hidakatsuya/flexdot-go/internal/fsys"
+)
+
+type Options struct {
+	BackupDir string
+	HomeDir   string
+	Snapshot  string    // defaults to the newest snapshot
+	FS        fsys.FS   // nil means the real filesystem
+	Out       io.Writer // receives a line per restored file; nil discards them
+}
+
+// Run moves the files of a backup snapshot back into the home directory.
+// Symlinks at the destination are replaced; other existing files are left
+// untouched. Files that cannot be restored are reported in a *RestoreError,
+// and the snapshot is then kept.
+func Run(opts Options) error {
+	filesystem := fsys.Or(opts.FS)
+	out := opts.Out
+	if out == nil {
+		out = io.Discard
+	}
+	store := backup.NewStore(filesystem, opts.BackupDir)
+	snapshot, err := findSnapshot(store, opts.Snapshot)
+	if err != nil {
+		return err
+	}
+
+	files, err := store.Files(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to read backup %s: %w", snapshot.Name, err)
+	}
+
+	var errs []*FileError
+	for _, rel := range files {
+		dest := backup.OriginalPath(opts.HomeDir, rel)
+		if err := restoreFile(filesystem, filepath.Join(snapshot.Path, rel), dest); err != nil {
+			errs = append(errs, &FileError{Path: dest, Op: "restore", Err: err})
+			continue
+		}
+		fmt.Fprintf(out, "\033[32mrestored:\033[0m %s\n", displayPath(opts.HomeDir, dest))
+	}
+
+	if len(errs) > 0 {
+		return &RestoreError{Errors: errs}
+	}
+	return store.Remove(snapshot)
+}
+
+func findSnapshot(store *backup.Store, name string) (backup.Snapshot, error) {
+	snapshots, err := store.Snapshots()
+	if err != nil {
+		return backup.Snapshot{}, fmt.Errorf("failed to list backups: %w", err)
+	}
+	if len(snapshots) == 0 {
+		return backup.Snapshot{}, fmt.Errorf("no backups found")
+	}
+	if name == "" {
+		return snapshots[len(snapshots)-1], nil
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.Name == name {
+			return snapshot, nil
+		}
+	}
+	return backup.Snapshot{}, fmt.Errorf("backup snapshot not found: %s", name)
+}
+
+// displayPath returns dest relative to the home directory, or as it is
+// when it is outside.
+func displayPath(homeDir, dest string) string {
+	if rel, err := filepath.Rel(homeDir, dest); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return rel
+	}
+	return dest
+}
+
+func restoreFile(filesystem fsys.FS, src, dest string) error {
+	fi, err := filesystem.Lstat(dest)
+	switch {
+	case err == nil && fi.Mode()&fs.ModeSymlink != 0:
+		if err := filesystem.Remove(dest); err != nil {
+			return err
+		}
+	case err == nil:
+		return fs.ErrExist
+	case !errors.Is(err, fs.ErrNotExist):
+		return err
+	}
+
+	if err := filesystem.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	return filesystem.Rename(src, dest)
+}
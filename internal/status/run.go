@@ -0,0 +1,96 @@
+package status
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hidakatsuya/flexdot-go/internal/install"
+)
+
+// Run prints the link state of every entry of the index file without
+// changing anything. It fails when a source is missing or a managed link
+// is broken.
+func Run(opts install.Options) error {
+	if opts.Warn == nil {
+		opts.Warn = install.OutputWarning
+	}
+	installer := install.New(opts)
+	entries, err := installer.Entries()
+	if err != nil {
+		return err
+	}
+
+	steps, planErr := installer.Plan(entries)
+	problems := 0
+	for _, step := range steps {
+		if outputState(opts.HomeDir, step) {
+			problems++
+		}
+	}
+	if planErr != nil {
+		for _, err := range planErr.Errors {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		problems += len(planErr.Errors)
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("%d entries need attention", problems)
+	}
+	return nil
+}
+
+// outputState prints the state of step and reports whether it is a problem.
+func outputState(homeDir string, step install.Step) bool {
+	var state, colorCode, note string
+	problem := false
+
+	switch step.Action {
+	case install.ActionNone:
+		state = "linked:"
+		colorCode = "\033[90m" // gray
+	case install.ActionCreate:
+		state = "not linked:"
+		colorCode = "\033[33m" // yellow
+	case install.ActionUpdate:
+		if _, err := os.Stat(step.HomeFile); err != nil {
+			state = "broken link:"
+			colorCode = "\033[31m" // red
+			note = "source moved"
+			problem = true
+		} else {
+			state = "stale link:"
+			colorCode = "\033[33m" // yellow
+		}
+	case install.ActionReplace:
+		state = "conflict:"
+		colorCode = "\033[33m" // yellow
+		note = "file exists"
+	case install.ActionReplaceLink:
+		state = "conflict:"
+		colorCode = "\033[33m" // yellow
+		note = "links to " + step.LinkDest
+	case install.ActionMissingSource:
+		if step.LinkDest == step.Dotfile {
+			state = "broken link:"
+			note = "source deleted"
+		} else {
+			state = "missing source:"
+		}
+		colorCode = "\033[31m" // red
+		problem = true
+	}
+
+	relPath, err := filepath.Rel(homeDir, step.HomeFile)
+	if err != nil {
+		relPath = step.HomeFile
+	}
+
+	msg := colorCode + state + "\033[0m " + relPath
+	if note != "" {
+		msg += " (" + note + ")"
+	}
+	fmt.Println(msg)
+	return problem
+}
@@ -0,0 +1,172 @@
+// Package flexdot lets other Go programs drive flexdot installs without
+// shelling out to the flexdot binary.
+//
+//	engine, err := flexdot.New(flexdot.Options{
+//		IndexFile:   "macOS.yml",
+//		HomeDir:     os.Getenv("HOME"),
+//		DotfilesDir: "/path/to/dotfiles",
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	plan, err := engine.Plan()
+//	if err != nil {
+//		return err
+//	}
+//	return engine.Apply(plan)
+package flexdot
+
+import (
+	"errors"
+	"path/filepath"
+
+	"github.com/hidakatsuya/flexdot-go/internal/backup"
+	"github.com/hidakatsuya/flexdot-go/internal/fsys"
+	"github.com/hidakatsuya/flexdot-go/internal/install"
+	"github.com/hidakatsuya/flexdot-go/internal/restore"
+)
+
+type (
+	// Options configures an Engine. IndexFile may be relative to DotfilesDir.
+	Options = install.Options
+	// Entry is a dotfile/home path pair resolved from the index file.
+	Entry = install.Entry
+	// Step is a planned change for a single Entry.
+	Step = install.Step
+	// Action is what Apply will do for a Step.
+	Action = install.Action
+	// Status is the result passed to a Reporter.
+	Status = install.Status
+	// StatusResult is the kind of result in a Status.
+	StatusResult = install.StatusResult
+	// Reporter is called once for every applied Step.
+	Reporter = install.Reporter
+	// Resolution is how a regular file in the way of a link is dealt with.
+	Resolution = install.Resolution
+	// Resolver decides the Resolution of a Step with ActionReplace.
+	Resolver = install.Resolver
+	// InstallError collects the per-entry failures of a Plan or Apply.
+	InstallError = install.InstallError
+	// EntryError describes the failure of a single Entry.
+	EntryError = install.EntryError
+	// RestoreError collects the per-file failures of a Restore.
+	RestoreError = restore.RestoreError
+	// FileError describes the failure to restore a single backed up file.
+	FileError = restore.FileError
+	// FS is the filesystem used by an Engine. Leave Options.FS nil to use
+	// the real filesystem.
+	FS = fsys.FS
+)
+
+const (
+	ActionNone          = install.ActionNone
+	ActionCreate        = install.ActionCreate
+	ActionUpdate        = install.ActionUpdate
+	ActionReplace       = install.ActionReplace
+	ActionReplaceLink   = install.ActionReplaceLink
+	ActionMissingSource = install.ActionMissingSource
+
+	AlreadyLinked = install.AlreadyLinked
+	LinkUpdated   = install.LinkUpdated
+	LinkCreated   = install.LinkCreated
+	MissingSource = install.MissingSource
+	Skipped       = install.Skipped
+
+	ResolveBackup = install.ResolveBackup
+	ResolveAdopt  = install.ResolveAdopt
+	ResolveSkip   = install.ResolveSkip
+)
+
+// Adopt is a Resolver moving every conflicting home file over its dotfile
+// instead of backing it up.
+var Adopt Resolver = install.Adopt
+
+// ErrNoMatches is passed to Options.Warn, or returned when Options.Strict is
+// set, for each wildcard of the index that matches no files.
+var ErrNoMatches = install.ErrNoMatches
+
+// ErrDuplicateDestination is returned by Entries and Plan when two entries
+// of the index link the same path.
+var ErrDuplicateDestination = install.ErrDuplicateDestination
+
+// Engine installs the dotfiles described by an index file.
+type Engine struct {
+	opts      Options
+	installer *install.Installer
+}
+
+// Plan is the set of steps computed by Engine.Plan.
+type Plan struct {
+	Steps []Step
+}
+
+// New validates opts and returns an Engine.
+func New(opts Options) (*Engine, error) {
+	if opts.IndexFile == "" {
+		return nil, errors.New("flexdot: IndexFile must be specified")
+	}
+	if opts.HomeDir == "" {
+		return nil, errors.New("flexdot: HomeDir must be specified")
+	}
+	if opts.DotfilesDir == "" {
+		return nil, errors.New("flexdot: DotfilesDir must be specified")
+	}
+
+	dotfilesDir, err := filepath.Abs(opts.DotfilesDir)
+	if err != nil {
+		return nil, err
+	}
+	opts.DotfilesDir = dotfilesDir
+	if !filepath.IsAbs(opts.IndexFile) {
+		opts.IndexFile = filepath.Join(dotfilesDir, opts.IndexFile)
+	}
+	if opts.BackupDir == "" {
+		opts.BackupDir = backup.DefaultDir(dotfilesDir)
+	}
+	return &Engine{opts: opts, installer: install.New(opts)}, nil
+}
+
+// Entries returns the entries of the index file with wildcards expanded.
+func (e *Engine) Entries() ([]Entry, error) {
+	return e.installer.Entries()
+}
+
+// Plan computes the steps needed to install the index without touching the
+// home directory. When some entries cannot be planned, the returned Plan
+// still holds the remaining steps and the error is an *InstallError.
+func (e *Engine) Plan() (*Plan, error) {
+	entries, err := e.installer.Entries()
+	if err != nil {
+		return nil, err
+	}
+	steps, planErr := e.installer.Plan(entries)
+	if planErr != nil {
+		return &Plan{Steps: steps}, planErr
+	}
+	return &Plan{Steps: steps}, nil
+}
+
+// Apply performs the steps of plan. The error, if any, is an *InstallError.
+func (e *Engine) Apply(plan *Plan) error {
+	if err := e.installer.Apply(plan.Steps); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Install plans and applies the index in one go, as `flexdot install` does.
+func (e *Engine) Install() error {
+	return e.installer.Install()
+}
+
+// Restore moves the files of the backup snapshot named snapshot, or of the
+// newest one when it is empty, back to where they were, as `flexdot restore`
+// does. Files that cannot be restored are reported in a *RestoreError.
+func (e *Engine) Restore(snapshot string) error {
+	return restore.Run(restore.Options{
+		BackupDir: e.opts.BackupDir,
+		HomeDir:   e.opts.HomeDir,
+		Snapshot:  snapshot,
+		FS:        e.opts.FS,
+	})
+}
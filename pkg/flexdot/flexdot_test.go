@@ -0,0 +1,145 @@
+package flexdot_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hidakatsuya/flexdot-go/pkg/flexdot"
+)
+
+func TestEnginePlanAndApply(t *testing.T) {
+	workDir := t.TempDir()
+
+	// Prepare dotfiles dir, file and index.yml
+	dotfilesDir := filepath.Join(workDir, "dotfiles")
+	if err := os.MkdirAll(dotfilesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dotfilesDir, "myfile.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dotfilesDir, "index.yml"), []byte(`myfile.txt: .`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	homeDir := filepath.Join(workDir, "home")
+	if err := os.MkdirAll(homeDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var reported []flexdot.StatusResult
+	engine, err := flexdot.New(flexdot.Options{
+		IndexFile:   "index.yml",
+		HomeDir:     homeDir,
+		DotfilesDir: dotfilesDir,
+		Reporter: func(step flexdot.Step, status *flexdot.Status) {
+			reported = append(reported, status.Result)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Plan must not touch the home dir
+	plan, err := engine.Plan()
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if len(plan.Steps) != 1 || plan.Steps[0].Action != flexdot.ActionCreate {
+		t.Fatalf("unexpected plan: %+v", plan.Steps)
+	}
+	linkPath := filepath.Join(homeDir, "myfile.txt")
+	if _, err := os.Lstat(linkPath); err == nil {
+		t.Fatalf("Plan should not create %s", linkPath)
+	}
+
+	if err := engine.Apply(plan); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	dest, err := os.Readlink(linkPath)
+	if err != nil {
+		t.Fatalf("failed to read symlink: %v", err)
+	}
+	if expected := filepath.Join(dotfilesDir, "myfile.txt"); dest != expected {
+		t.Errorf("symlink points to %s, want %s", dest, expected)
+	}
+	if len(reported) != 1 || reported[0] != flexdot.LinkCreated {
+		t.Errorf("unexpected reports: %v", reported)
+	}
+
+	// A second plan sees the link as already installed
+	plan, err = engine.Plan()
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if len(plan.Steps) != 1 || plan.Steps[0].Action != flexdot.ActionNone {
+		t.Errorf("unexpected plan after apply: %+v", plan.Steps)
+	}
+}
+
+func TestEngineRestoreReportsFileErrors(t *testing.T) {
+	workDir := t.TempDir()
+	dotfilesDir := filepath.Join(workDir, "dotfiles")
+	homeDir := filepath.Join(workDir, "home")
+	for path, content := range map[string]string{
+		filepath.Join(dotfilesDir, "myfile.txt"): "hello",
+		filepath.Join(dotfilesDir, "index.yml"):  "myfile.txt: .",
+		filepath.Join(homeDir, "myfile.txt"):     "local",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	engine, err := flexdot.New(flexdot.Options{
+		IndexFile:   "index.yml",
+		HomeDir:     homeDir,
+		DotfilesDir: dotfilesDir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Install(); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+
+	// A regular file in the way is not overwritten
+	homeFile := filepath.Join(homeDir, "myfile.txt")
+	if err := os.Remove(homeFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(homeFile, []byte("newer"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = engine.Restore("")
+	var restoreErr *flexdot.RestoreError
+	if !errors.As(err, &restoreErr) || len(restoreErr.Errors) != 1 {
+		t.Fatalf("expected a RestoreError with one file, got %v", err)
+	}
+	if e := restoreErr.Errors[0]; e.Path != homeFile || !errors.Is(e, fs.ErrExist) {
+		t.Errorf("unexpected file error: %v", e)
+	}
+
+	// The backup is kept, so the restore can be retried
+	if err := os.Remove(homeFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Restore(""); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if data, err := os.ReadFile(homeFile); err != nil || string(data) != "local" {
+		t.Errorf("expected the backed up file to be restored, got %q (%v)", data, err)
+	}
+}
+
+func TestNewRequiresOptions(t *testing.T) {
+	if _, err := flexdot.New(flexdot.Options{HomeDir: "/home", DotfilesDir: "/dotfiles"}); err == nil {
+		t.Error("expected an error without IndexFile")
+	}
+}